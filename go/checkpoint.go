@@ -0,0 +1,149 @@
+package rosco
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/autonomouskoi/akcore"
+)
+
+var (
+	runnerCheckpointsKVKey = []byte("runners")
+)
+
+// checkpointIntervalMS is the least time between checkpoints written only
+// because running scripts moved on a step
+const checkpointIntervalMS = 1000
+
+// checkpoint is the runner's state as stored to resume it after a restart, or
+// nil if the runner was given bare actions by runScript rather than a script.
+// Everything started through startScript, scene fades included, has one.
+func (sr *scriptRunner) checkpoint() *RunnerCheckpoint {
+	if sr.script == nil {
+		return nil
+	}
+	cp := &RunnerCheckpoint{
+		Target:      sr.target,
+		Script:      sr.script,
+		Step:        uint32(len(sr.script.GetActions()) - len(sr.steps)),
+		Started:     sr.started,
+		StartedAtMs: sr.startedAt,
+		DueMs:       sr.due,
+		SleepBeats:  sr.sleeping != nil,
+	}
+	if fade := sr.currentFade; fade != nil {
+		cp.Fading = true
+		cp.FadeStartMs = fade.startTime
+		cp.FadeEndMs = fade.endTime
+	}
+	return cp
+}
+
+// writeRunnerCheckpoints stores the running scripts and scheduled runs if
+// they've changed since they were last stored. Fades and tracks keep their
+// start and end times, so a frame being sent isn't a change. Scripts moving on
+// a step are stored at most every checkpointIntervalMS, so a restart may
+// resume them a little before where they were.
+func (rsc *Rosco) writeRunnerCheckpoints(now int64) {
+	if !rsc.runnersChanged && (!rsc.runnersStepped || now-rsc.checkpointedAt < checkpointIntervalMS) {
+		return
+	}
+	rsc.runnersChanged, rsc.runnersStepped = false, false
+	rsc.checkpointedAt = now
+	ids := make([]int, 0, len(rsc.runners))
+	for id := range rsc.runners {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	cps := &RunnerCheckpoints{}
+	for _, id := range ids {
+		if cp := rsc.runners[id].checkpoint(); cp != nil {
+			cps.Runners = append(cps.Runners, cp)
+		}
+	}
+	for _, id := range rsc.scheduledIDs() {
+		run := rsc.scheduledRuns[id]
+		cps.Scheduled = append(cps.Scheduled, &ScheduledRunCheckpoint{
+			Id:        id,
+			Target:    run.target,
+			Script:    run.script,
+			StartAtMs: run.at,
+		})
+	}
+	if err := rsc.kvSetProto(runnerCheckpointsKVKey, cps); err != nil {
+		rsc.host.LogError("writing runner checkpoints", "error", err.Error())
+	}
+}
+
+// loadRunnerCheckpoints resumes the running scripts and scheduled runs stored
+// before a restart whose scripts' policy is to resume, and drops the rest
+func (rsc *Rosco) loadRunnerCheckpoints() error {
+	cps := &RunnerCheckpoints{}
+	err := rsc.kvGetProto(runnerCheckpointsKVKey, cps)
+	if errors.Is(err, akcore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	rsc.runnersChanged = true
+	for _, cp := range cps.GetRunners() {
+		if cp.GetScript().GetRestart() != RestartPolicy_RestartResume {
+			rsc.host.LogInfo("aborting script after restart",
+				"target", cp.GetTarget(),
+				"name", cp.GetScript().GetName(),
+			)
+			continue
+		}
+		if int(cp.GetStep()) > len(cp.GetScript().GetActions()) {
+			rsc.host.LogError("bad runner checkpoint",
+				"target", cp.GetTarget(),
+				"name", cp.GetScript().GetName(),
+				"step", cp.GetStep(),
+			)
+			continue
+		}
+		rsc.resumeRunner(cp)
+	}
+	for _, run := range cps.GetScheduled() {
+		if run.GetScript().GetRestart() != RestartPolicy_RestartResume {
+			rsc.host.LogInfo("aborting scheduled run after restart",
+				"id", run.GetId(),
+				"target", run.GetTarget(),
+				"name", run.GetScript().GetName(),
+			)
+			continue
+		}
+		rsc.scheduledCount = max(rsc.scheduledCount, run.GetId())
+		rsc.scheduledRuns[run.GetId()] = &scheduledRun{
+			target: run.GetTarget(),
+			script: run.GetScript(),
+			at:     run.GetStartAtMs(),
+		}
+	}
+	return nil
+}
+
+// resumeRunner starts running a script from a checkpoint, where it would
+// have been had the plugin not restarted
+func (rsc *Rosco) resumeRunner(cp *RunnerCheckpoint) {
+	script := cp.GetScript()
+	sr := rsc.startScript(cp.GetTarget(), script)
+	step := int(cp.GetStep())
+	sr.steps = script.GetActions()[step:]
+	sr.started, sr.startedAt, sr.due = cp.GetStarted(), cp.GetStartedAtMs(), cp.GetDueMs()
+	if cp.GetSleepBeats() && step > 0 {
+		sr.sleeping = script.GetActions()[step-1]
+	}
+	if cp.GetFading() && step > 0 {
+		sr.startFade(script.GetActions()[step-1], cp.GetFadeStartMs())
+		if sr.currentFade != nil {
+			sr.currentFade.endTime = cp.GetFadeEndMs()
+		}
+	}
+	rsc.host.LogInfo("resumed script after restart",
+		"target", cp.GetTarget(),
+		"name", script.GetName(),
+		"step", cp.GetStep(),
+	)
+}
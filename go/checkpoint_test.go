@@ -0,0 +1,145 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumeAfterRestart(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	resumed := &Script{Name: "show", Restart: RestartPolicy_RestartResume, Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSet, Address: "/a", Values: float32Values(1)},
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1000},
+		{Type: ScriptActionType_ActionTypeFade, Address: "/fader", Values: float32Values(0, 1), DurationMs: 1000},
+		{Type: ScriptActionType_ActionTypeSet, Address: "/b", Values: float32Values(1)},
+	}}
+	aborted := &Script{Name: "loop", Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSet, Address: "/x", Values: float32Values(1)},
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 5000},
+		{Type: ScriptActionType_ActionTypeSet, Address: "/y", Values: float32Values(1)},
+	}}
+	for _, srr := range []*ScriptRunRequest{
+		{Target: "mixer", Script: resumed},
+		{Target: "lights", Script: aborted},
+		{Target: "overlay", Script: resumed, StartAtMs: 4000},
+		{Target: "overlay", Script: aborted, StartAtMs: 4000},
+	} {
+		fh.now = 1000
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), srr)
+		require.Nil(t, reply.Error)
+	}
+	tick(t, rsc, fh, 1000)
+	require.Len(t, fh.takeSent(), 2)
+	tick(t, rsc, fh, 2500)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/fader", values: float32Values(0.5)}}, fh.takeSent())
+
+	// the plugin restarts with the same KV store
+	fh.logs = nil
+	rsc, err := newRosco(fh)
+	require.NoError(t, err)
+	require.Len(t, rsc.runners, 1)
+	require.Equal(t, []*ScheduledRun{
+		{Id: 1, Target: "overlay", ScriptName: "show", StartAtMs: 4000},
+	}, rsc.scheduledRunList())
+	require.Equal(t, int32(1), rsc.scheduledCount, "only resumed runs are counted")
+	var messages []string
+	for _, entry := range fh.logs {
+		if entry.level == "info" {
+			messages = append(messages, entry.message)
+		}
+	}
+	require.Equal(t, []string{
+		"resumed script after restart",
+		"aborting script after restart",
+		"aborting scheduled run after restart",
+	}, messages)
+
+	tick(t, rsc, fh, 2750)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/fader", values: float32Values(0.75)}}, fh.takeSent(),
+		"the fade carries on from where it should be",
+	)
+	tick(t, rsc, fh, 3000)
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/fader", values: float32Values(1)},
+		{target: "mixer", address: "/b", values: float32Values(1)},
+	}, fh.takeSent())
+	tick(t, rsc, fh, 4000)
+	require.Equal(t, []sentMessage{{target: "overlay", address: "/a", values: float32Values(1)}}, fh.takeSent())
+	tick(t, rsc, fh, 9000)
+	require.Equal(t, []sentMessage{
+		{target: "overlay", address: "/fader", values: float32Values(1)},
+		{target: "overlay", address: "/b", values: float32Values(1)},
+	}, fh.takeSent(), "the aborted runs never send /y")
+
+	// a restart with nothing running has nothing to resume
+	rsc, err = newRosco(fh)
+	require.NoError(t, err)
+	require.Empty(t, rsc.runners)
+	require.Empty(t, rsc.scheduledRuns)
+}
+
+func TestCheckpointsCoalesced(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	var actions []*ScriptAction
+	for range 20 {
+		actions = append(actions, &ScriptAction{Type: ScriptActionType_ActionTypeSleep, DurationMs: 100})
+	}
+	script := &Script{Restart: RestartPolicy_RestartResume, Actions: actions}
+	fh.now = 1000
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "mixer",
+		Script: script,
+	})
+	require.Nil(t, reply.Error)
+	storedSteps := func() []uint32 {
+		t.Helper()
+		cps := &RunnerCheckpoints{}
+		require.NoError(t, rsc.kvGetProto(runnerCheckpointsKVKey, cps))
+		var steps []uint32
+		for _, cp := range cps.GetRunners() {
+			steps = append(steps, cp.GetStep())
+		}
+		return steps
+	}
+
+	tick(t, rsc, fh, 1000)
+	require.Equal(t, []uint32{1}, storedSteps(), "a new runner is stored straight away")
+	tick(t, rsc, fh, 1100)
+	tick(t, rsc, fh, 1900)
+	require.Equal(t, []uint32{1}, storedSteps(), "steps aren't stored every tick")
+	tick(t, rsc, fh, 2000)
+	require.Equal(t, []uint32{11}, storedSteps())
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "lights",
+		Script: script,
+	})
+	require.Nil(t, reply.Error)
+	tick(t, rsc, fh, 2100)
+	require.Equal(t, []uint32{12, 1}, storedSteps(), "new runners aren't held back")
+}
+
+func TestScriptTextRestart(t *testing.T) {
+	t.Parallel()
+	text := "name \"show\"\n" +
+		"restart resume\n" +
+		"sleep 1s\n"
+	script, textErrs := parseScriptText(text)
+	require.Empty(t, textErrs)
+	require.Equal(t, RestartPolicy_RestartResume, script.GetRestart())
+	rendered, err := renderScriptText(script)
+	require.NoError(t, err)
+	require.Equal(t, text, rendered)
+
+	_, textErrs = parseScriptText("restart abort\nrestart never\n")
+	require.Equal(t, []*ScriptTextError{
+		{Line: 2, Column: 1, Message: "restart is already set"},
+	}, textErrs)
+	_, textErrs = parseScriptText("restart never\n")
+	require.Equal(t, []*ScriptTextError{
+		{Line: 1, Column: 9, Message: `unknown restart policy "never"`},
+	}, textErrs)
+}
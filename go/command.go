@@ -1,12 +1,48 @@
 package rosco
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/autonomouskoi/akcore"
 	"github.com/autonomouskoi/core-tinygo"
 )
 
 func (rsc *Rosco) handleCommands() core.TypeRouter {
 	return core.TypeRouter{
-		int32(MessageTypeCommand_CONFIG_SET_REQ): rsc.handleCommandConfigSet,
+		int32(MessageTypeCommand_CONFIG_SET_REQ):        rsc.handleCommandConfigSet,
+		int32(MessageTypeCommand_TRIGGER_ENABLE_REQ):    rsc.handleCommandTriggerEnable,
+		int32(MessageTypeCommand_TRIGGER_GROUP_ARM_REQ): rsc.handleCommandTriggerGroupArm,
+		int32(MessageTypeCommand_SCRIPT_CREATE_REQ):     rsc.handleCommandScriptCreate,
+		int32(MessageTypeCommand_SCRIPT_UPDATE_REQ):     rsc.handleCommandScriptUpdate,
+		int32(MessageTypeCommand_SCRIPT_DELETE_REQ):     rsc.handleCommandScriptDelete,
+		int32(MessageTypeCommand_TRIGGER_CREATE_REQ):    rsc.handleCommandTriggerCreate,
+		int32(MessageTypeCommand_TRIGGER_UPDATE_REQ):    rsc.handleCommandTriggerUpdate,
+		int32(MessageTypeCommand_TRIGGER_DELETE_REQ):    rsc.handleCommandTriggerDelete,
+		int32(MessageTypeCommand_CONFIG_RESTORE_REQ):    rsc.handleCommandConfigRestore,
+		int32(MessageTypeCommand_IMPORT_REQ):            rsc.handleCommandImport,
+		int32(MessageTypeCommand_SCENE_CAPTURE_REQ):     rsc.handleCommandSceneCapture,
+		int32(MessageTypeCommand_SCENE_DELETE_REQ):      rsc.handleCommandSceneDelete,
+		int32(MessageTypeCommand_CUE_LIST_SET_REQ):      rsc.handleCommandCueListSet,
+		int32(MessageTypeCommand_CUE_LIST_DELETE_REQ):   rsc.handleCommandCueListDelete,
+	}
+}
+
+// checkRevision returns a conflict error if base isn't the current config
+// revision, meaning the change was made against a stale config
+func (rsc *Rosco) checkRevision(base uint64) *core.Error {
+	if base == rsc.cfg.GetRevision() {
+		return nil
+	}
+	return &core.Error{
+		Code:           int32(ErrorCode_CONFLICT),
+		NotCommonError: true,
+		Detail: core.String(fmt.Sprintf("base revision %d doesn't match current revision %d",
+			base, rsc.cfg.GetRevision(),
+		)),
+		UserMessage: core.String("the config was changed by someone else, reload and try again"),
 	}
 }
 
@@ -16,11 +52,559 @@ func (rsc *Rosco) handleCommandConfigSet(msg *core.BusMessage) *core.BusMessage
 	if reply.Error = core.UnmarshalMessage(msg, csr); reply.Error != nil {
 		return reply
 	}
-	rsc.cfg = csr.GetConfig()
-	rsc.writeCfg()
+	if reply.Error = rsc.checkRevision(csr.GetConfig().GetRevision()); reply.Error != nil {
+		return reply
+	}
+	if cfgErrs := validateConfig(csr.GetConfig()); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		core.MarshalMessage(reply, &ConfigSetResponse{
+			Config: rsc.cfg,
+			Errors: cfgErrs,
+		})
+		rsc.host.LogError("rejecting invalid config", "error", reply.Error.GetDetail())
+		return reply
+	}
+	cfg := csr.GetConfig()
+	flagSnaps(cfg)
+	if err := rsc.writeCfg(cfg, csr.GetComment()); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
 	core.MarshalMessage(reply, &ConfigSetResponse{
 		Config: rsc.cfg,
 	})
-	core.LogDebug("saved config")
+	rsc.host.LogDebug("saved config")
+	return reply
+}
+
+func (rsc *Rosco) handleCommandTriggerEnable(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	ter := &TriggerEnableRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, ter); reply.Error != nil {
+		return reply
+	}
+	// this is run during shows, so the revision is only checked if given
+	if base := ter.GetBaseRevision(); base != 0 {
+		if reply.Error = rsc.checkRevision(base); reply.Error != nil {
+			return reply
+		}
+	}
+	cfg := rsc.cfg.CloneVT()
+	trigger, present := cfg.GetTriggers()[ter.GetTriggerId()]
+	if !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	trigger.Disabled = !ter.GetEnabled()
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("set trigger %s enabled to %t", ter.GetTriggerId(), ter.GetEnabled())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TriggerEnableResponse{
+		Trigger:  trigger,
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("set trigger enabled",
+		"trigger", ter.GetTriggerId(),
+		"enabled", ter.GetEnabled(),
+	)
+	return reply
+}
+
+func (rsc *Rosco) handleCommandTriggerGroupArm(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	tgar := &TriggerGroupArmRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, tgar); reply.Error != nil {
+		return reply
+	}
+	// this is run during shows, so the revision is only checked if given
+	if base := tgar.GetBaseRevision(); base != 0 {
+		if reply.Error = rsc.checkRevision(base); reply.Error != nil {
+			return reply
+		}
+	}
+	if tgar.GetGroup() == "" {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String("group name is required"),
+		}
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	if cfg.TriggerGroups == nil {
+		cfg.TriggerGroups = map[string]*TriggerGroup{}
+	}
+	group, present := cfg.TriggerGroups[tgar.GetGroup()]
+	if !present {
+		group = &TriggerGroup{}
+		cfg.TriggerGroups[tgar.GetGroup()] = group
+	}
+	group.Armed = tgar.GetArmed()
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("set trigger group %s armed to %t", tgar.GetGroup(), tgar.GetArmed())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TriggerGroupArmResponse{
+		Group:    group,
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("set trigger group armed",
+		"group", tgar.GetGroup(),
+		"armed", tgar.GetArmed(),
+	)
+	return reply
+}
+
+// nextScriptID returns a script ID unused in cfg
+func nextScriptID(cfg *Config) int32 {
+	var max int32
+	for id := range cfg.GetScripts() {
+		if id > max {
+			max = id
+		}
+	}
+	return max + 1
+}
+
+func (rsc *Rosco) handleCommandScriptCreate(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	scr := &ScriptCreateRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, scr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(scr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	id := nextScriptID(rsc.cfg)
+	cfg := rsc.cfg.CloneVT()
+	if cfg.Scripts == nil {
+		cfg.Scripts = map[int32]*Script{}
+	}
+	cfg.Scripts[id] = scr.GetScript()
+	if cfgErrs := validateConfig(cfg); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("created script %d", id)); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &ScriptCreateResponse{
+		ScriptId: id,
+		Script:   scr.GetScript(),
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("created script", "id", id)
+	return reply
+}
+
+func (rsc *Rosco) handleCommandScriptUpdate(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	sur := &ScriptUpdateRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, sur); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(sur.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetScripts()[sur.GetScriptId()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	// the rest of the config can depend on the script, e.g. triggers give
+	// targets for its roles, so check it all with the change made
+	cfg := rsc.cfg.CloneVT()
+	cfg.Scripts[sur.GetScriptId()] = sur.GetScript()
+	if cfgErrs := validateConfig(cfg); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("updated script %d", sur.GetScriptId())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &ScriptUpdateResponse{
+		ScriptId: sur.GetScriptId(),
+		Script:   sur.GetScript(),
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("updated script", "id", sur.GetScriptId())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandScriptDelete(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	sdr := &ScriptDeleteRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, sdr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(sdr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetScripts()[sdr.GetScriptId()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	var usedBy []string
+	for triggerID, trigger := range rsc.cfg.GetTriggers() {
+		if trigger.GetScriptId() == sdr.GetScriptId() {
+			usedBy = append(usedBy, "trigger "+triggerID)
+		}
+	}
+	for name, list := range rsc.cfg.GetCueLists() {
+		for _, cue := range list.GetCues() {
+			if _, ok := cue.GetSubject().(*Cue_ScriptId); ok && cue.GetScriptId() == sdr.GetScriptId() {
+				usedBy = append(usedBy, "cue list "+name)
+				break
+			}
+		}
+	}
+	if len(usedBy) > 0 {
+		sort.Strings(usedBy)
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(fmt.Sprintf("script is used by %s", strings.Join(usedBy, ", "))),
+		}
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	delete(cfg.Scripts, sdr.GetScriptId())
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("deleted script %d", sdr.GetScriptId())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &ScriptDeleteResponse{
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("deleted script", "id", sdr.GetScriptId())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandTriggerCreate(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	tcr := &TriggerCreateRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, tcr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(tcr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetTriggers()[tcr.GetTriggerId()]; present {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String("trigger already exists"),
+		}
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	if cfg.Triggers == nil {
+		cfg.Triggers = map[string]*Trigger{}
+	}
+	cfg.Triggers[tcr.GetTriggerId()] = tcr.GetTrigger()
+	if cfgErrs := validateConfig(cfg); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("created trigger %s", tcr.GetTriggerId())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TriggerCreateResponse{
+		TriggerId: tcr.GetTriggerId(),
+		Trigger:   tcr.GetTrigger(),
+		Revision:  rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("created trigger", "trigger", tcr.GetTriggerId())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandTriggerUpdate(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	tur := &TriggerUpdateRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, tur); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(tur.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetTriggers()[tur.GetTriggerId()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	cfg.Triggers[tur.GetTriggerId()] = tur.GetTrigger()
+	if cfgErrs := validateConfig(cfg); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("updated trigger %s", tur.GetTriggerId())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TriggerUpdateResponse{
+		TriggerId: tur.GetTriggerId(),
+		Trigger:   tur.GetTrigger(),
+		Revision:  rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("updated trigger", "trigger", tur.GetTriggerId())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandTriggerDelete(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	tdr := &TriggerDeleteRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, tdr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(tdr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetTriggers()[tdr.GetTriggerId()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	delete(cfg.Triggers, tdr.GetTriggerId())
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("deleted trigger %s", tdr.GetTriggerId())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TriggerDeleteResponse{
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("deleted trigger", "trigger", tdr.GetTriggerId())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandConfigRestore(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	crr := &ConfigRestoreRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, crr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(crr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	cr, err := rsc.loadRevision(crr.GetRevision())
+	if errors.Is(err, akcore.ErrNotFound) {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	if err != nil {
+		reply.Error = core.BusError(err)
+		rsc.host.LogError("loading config revision", "revision", crr.GetRevision(), "error", err.Error())
+		return reply
+	}
+	cfg := cr.GetConfig().CloneVT()
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	if err := migrateConfig(cfg); err != nil {
+		reply.Error = core.BusError(err)
+		rsc.host.LogError("migrating config revision", "revision", crr.GetRevision(), "error", err.Error())
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("restored revision %d", crr.GetRevision())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &ConfigRestoreResponse{
+		Config: rsc.cfg,
+	})
+	rsc.host.LogInfo("restored config", "revision", crr.GetRevision())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandImport(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	ir := &ImportRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, ir); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(ir.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	doc := &ExportDocument{}
+	if err := doc.UnmarshalJSON([]byte(ir.GetDocument())); err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(fmt.Sprintf("parsing document: %v", err)),
+		}
+		return reply
+	}
+	cfg, resp, err := importDocument(rsc.cfg, doc)
+	if err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+	if cfg == nil {
+		reply.Error = configErrorsError(resp.GetErrors())
+		core.MarshalMessage(reply, resp)
+		return reply
+	}
+	comment := fmt.Sprintf("imported %d scripts and %d triggers",
+		len(resp.GetScriptIds()), len(resp.GetTriggerIds()),
+	)
+	if err := rsc.writeCfg(cfg, comment); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	resp.Revision = rsc.cfg.GetRevision()
+	core.MarshalMessage(reply, resp)
+	rsc.host.LogInfo("imported",
+		"scripts", int32(len(resp.GetScriptIds())),
+		"triggers", int32(len(resp.GetTriggerIds())),
+		"conflicts", int32(len(resp.GetConflicts())),
+	)
+	return reply
+}
+
+func (rsc *Rosco) handleCommandSceneCapture(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	scr := &SceneCaptureRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, scr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(scr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	id := scr.GetSceneId()
+	if id == 0 {
+		id = nextSceneID(rsc.cfg)
+	} else if _, present := rsc.cfg.GetScenes()[id]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	scene := rsc.captureScene(scr.GetName(), scr.GetTargets())
+	if len(scene.GetValues()) == 0 {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String("nothing has been sent to capture"),
+		}
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	if cfg.Scenes == nil {
+		cfg.Scenes = map[int32]*Scene{}
+	}
+	cfg.Scenes[id] = scene
+	if cfgErrs := validateConfig(cfg); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("captured scene %d", id)); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &SceneCaptureResponse{
+		SceneId:  id,
+		Scene:    scene,
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("captured scene", "id", id, "values", int32(len(scene.GetValues())))
+	return reply
+}
+
+func (rsc *Rosco) handleCommandSceneDelete(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	sdr := &SceneDeleteRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, sdr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(sdr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetScenes()[sdr.GetSceneId()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	var usedBy []string
+	for name, list := range rsc.cfg.GetCueLists() {
+		for _, cue := range list.GetCues() {
+			if _, ok := cue.GetSubject().(*Cue_SceneId); ok && cue.GetSceneId() == sdr.GetSceneId() {
+				usedBy = append(usedBy, "cue list "+name)
+				break
+			}
+		}
+	}
+	if len(usedBy) > 0 {
+		sort.Strings(usedBy)
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(fmt.Sprintf("scene is used by %s", strings.Join(usedBy, ", "))),
+		}
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	delete(cfg.Scenes, sdr.GetSceneId())
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("deleted scene %d", sdr.GetSceneId())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &SceneDeleteResponse{
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("deleted scene", "id", sdr.GetSceneId())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandCueListSet(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	clsr := &CueListSetRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, clsr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(clsr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	if cfg.CueLists == nil {
+		cfg.CueLists = map[string]*CueList{}
+	}
+	cfg.CueLists[clsr.GetCueList()] = clsr.GetList()
+	if cfgErrs := validateConfig(cfg); len(cfgErrs) > 0 {
+		reply.Error = configErrorsError(cfgErrs)
+		return reply
+	}
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("set cue list %s", clsr.GetCueList())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &CueListSetResponse{
+		CueList:  clsr.GetCueList(),
+		List:     clsr.GetList(),
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("set cue list", "cue_list", clsr.GetCueList())
+	return reply
+}
+
+func (rsc *Rosco) handleCommandCueListDelete(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	cldr := &CueListDeleteRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, cldr); reply.Error != nil {
+		return reply
+	}
+	if reply.Error = rsc.checkRevision(cldr.GetBaseRevision()); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetCueLists()[cldr.GetCueList()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	cfg := rsc.cfg.CloneVT()
+	delete(cfg.CueLists, cldr.GetCueList())
+	if err := rsc.writeCfg(cfg, fmt.Sprintf("deleted cue list %s", cldr.GetCueList())); err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	rsc.clearCueState(cldr.GetCueList())
+	core.MarshalMessage(reply, &CueListDeleteResponse{
+		Revision: rsc.cfg.GetRevision(),
+	})
+	rsc.host.LogDebug("deleted cue list", "cue_list", cldr.GetCueList())
 	return reply
 }
@@ -0,0 +1,229 @@
+package rosco
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/autonomouskoi/akcore"
+)
+
+var (
+	cueStateKVKey = []byte("cue-state")
+)
+
+// a pendingCue is a cue that has been started and is waiting to run
+type pendingCue struct {
+	index int32
+	at    int64
+}
+
+// loadCueStates retrieves the playback position of each cue list, including
+// cues that were waiting to run
+func (rsc *Rosco) loadCueStates() error {
+	rsc.cueStates = &CueListStates{}
+	err := rsc.kvGetProto(cueStateKVKey, rsc.cueStates)
+	if err != nil && !errors.Is(err, akcore.ErrNotFound) {
+		return err
+	}
+	if rsc.cueStates.Lists == nil {
+		rsc.cueStates.Lists = map[string]*CueListState{}
+	}
+	for name, state := range rsc.cueStates.Lists {
+		if state.GetPending() && state.GetNext() > 0 {
+			rsc.pendingCues[name] = &pendingCue{index: state.GetNext() - 1, at: state.GetPendingAtMs()}
+		}
+	}
+	return nil
+}
+
+// setNextCue sets the cue GO runs next in a cue list and stores it so
+// playback resumes there after a restart
+func (rsc *Rosco) setNextCue(name string, index int32) {
+	rsc.cueStates.Lists[name] = &CueListState{Next: index}
+	rsc.writeCueStates()
+}
+
+// clearCueState forgets the playback position of a cue list
+func (rsc *Rosco) clearCueState(name string) {
+	delete(rsc.pendingCues, name)
+	delete(rsc.cueStates.Lists, name)
+	rsc.writeCueStates()
+}
+
+// writeCueStates stores the playback position of each cue list along with
+// the cues waiting to run
+func (rsc *Rosco) writeCueStates() {
+	for name, state := range rsc.cueStates.Lists {
+		state.Pending, state.PendingAtMs = false, 0
+		if pc, present := rsc.pendingCues[name]; present {
+			state.Pending, state.PendingAtMs = true, pc.at
+		}
+	}
+	if err := rsc.kvSetProto(cueStateKVKey, rsc.cueStates); err != nil {
+		rsc.host.LogError("writing cue state", "error", err.Error())
+	}
+}
+
+// cueStatus reports the playback position of a cue list
+func (rsc *Rosco) cueStatus(name string) *CueListStatus {
+	count := int32(len(rsc.cfg.GetCueLists()[name].GetCues()))
+	next := rsc.cueStates.GetLists()[name].GetNext()
+	status := &CueListStatus{
+		CueList: name,
+		Current: min(next, count) - 1,
+		Next:    next,
+	}
+	if next >= count {
+		status.Next = -1
+	}
+	_, status.Pending = rsc.pendingCues[name]
+	return status
+}
+
+// startCue makes the cue at index the current cue and runs it delayMS plus
+// its wait from now. The cue runs immediately if there's nothing to wait for.
+func (rsc *Rosco) startCue(name string, list *CueList, index int32, now, delayMS int64) error {
+	at := now + delayMS + int64(list.GetCues()[index].GetWaitMs())
+	if at <= now {
+		rsc.setNextCue(name, index+1)
+		return rsc.runCue(name, list, index, now)
+	}
+	rsc.pendingCues[name] = &pendingCue{index: index, at: at}
+	rsc.setNextCue(name, index+1)
+	return nil
+}
+
+// runCue runs the cue at index and starts the next cue if this one is
+// followed automatically. If the runner limits refuse the cue's script, the
+// cue is made the next cue again and the refusal is returned.
+func (rsc *Rosco) runCue(name string, list *CueList, index int32, now int64) error {
+	if _, present := rsc.pendingCues[name]; present {
+		delete(rsc.pendingCues, name)
+		rsc.writeCueStates()
+	}
+	cue := list.GetCues()[index]
+	var durationMS uint32
+	switch cue.GetSubject().(type) {
+	case *Cue_ScriptId:
+		script, present := rsc.cfg.GetScripts()[cue.GetScriptId()]
+		if !present {
+			rsc.host.LogError("bad script in cue",
+				"cue_list", name,
+				"cue", index,
+				"script_id", cue.GetScriptId(),
+			)
+			return nil
+		}
+		script, err := resolveRoles(script, cue.GetRoles())
+		if err != nil {
+			rsc.host.LogError("bad roles in cue",
+				"cue_list", name,
+				"cue", index,
+				"error", err.Error(),
+			)
+			return nil
+		}
+		err = rsc.admitRun("cue list "+name, cue.GetTarget(), script, func() error {
+			return rsc.checkRunnerCount(cue.GetTarget(), script)
+		})
+		if err != nil {
+			rsc.setNextCue(name, index)
+			return refusedError{err}
+		}
+		sr := rsc.startScript(cue.GetTarget(), script)
+		durationMS = scriptTimeline(script, sr.tempo).GetDurationMs()
+	case *Cue_SceneId:
+		scene, present := rsc.cfg.GetScenes()[cue.GetSceneId()]
+		if !present {
+			rsc.host.LogError("bad scene in cue",
+				"cue_list", name,
+				"cue", index,
+				"scene_id", cue.GetSceneId(),
+			)
+			return nil
+		}
+		rsc.recallScene("cue list "+name, scene, cue.GetFadeMs(), FadeCurve_CurveLinear)
+		durationMS = cue.GetFadeMs()
+	}
+	rsc.host.LogDebug("ran cue", "cue_list", name, "cue", index, "name", cue.GetName())
+
+	next := index + 1
+	if int(next) >= len(list.GetCues()) {
+		return nil
+	}
+	// a refused follow-on cue is left as the next cue for GO to retry, it
+	// doesn't undo this one
+	switch cue.GetFollow() {
+	case CueFollow_FollowAutoContinue:
+		rsc.startCue(name, list, next, now, 0)
+	case CueFollow_FollowAutoFollow:
+		rsc.startCue(name, list, next, now, int64(durationMS))
+	}
+	return nil
+}
+
+// runPendingCues runs the cues that are done waiting
+func (rsc *Rosco) runPendingCues(now int64) {
+	names := make([]string, 0, len(rsc.pendingCues))
+	for name := range rsc.pendingCues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pc := rsc.pendingCues[name]
+		if pc == nil || pc.at > now {
+			continue
+		}
+		list := rsc.cfg.GetCueLists()[name]
+		if int(pc.index) >= len(list.GetCues()) {
+			// the cue list changed while the cue was waiting
+			delete(rsc.pendingCues, name)
+			rsc.writeCueStates()
+			continue
+		}
+		rsc.runCue(name, list, pc.index, now)
+	}
+}
+
+// cueGo runs the next cue in a cue list. If the current cue is still waiting
+// it runs now instead.
+func (rsc *Rosco) cueGo(name string, list *CueList, now int64) error {
+	if pc, present := rsc.pendingCues[name]; present {
+		return rsc.runCue(name, list, pc.index, now)
+	}
+	next := rsc.cueStates.GetLists()[name].GetNext()
+	if int(next) >= len(list.GetCues()) {
+		return errors.New("at the end of the cue list")
+	}
+	return rsc.startCue(name, list, next, now, 0)
+}
+
+// cueBack runs the cue before the current cue. If it's refused, the cue list
+// is left where it was.
+func (rsc *Rosco) cueBack(name string, list *CueList, now int64) error {
+	current := rsc.cueStatus(name).GetCurrent()
+	if current < 1 {
+		return errors.New("no cue before the current cue")
+	}
+	next, pending := rsc.cueStates.GetLists()[name].GetNext(), rsc.pendingCues[name]
+	delete(rsc.pendingCues, name)
+	err := rsc.startCue(name, list, current-1, now, 0)
+	if err != nil {
+		if pending != nil {
+			rsc.pendingCues[name] = pending
+		}
+		rsc.setNextCue(name, next)
+	}
+	return err
+}
+
+// cueJump makes the cue at index the next cue without running anything
+func (rsc *Rosco) cueJump(name string, list *CueList, index int32) error {
+	if index < 0 || int(index) >= len(list.GetCues()) {
+		return fmt.Errorf("cue index %d out of range", index)
+	}
+	delete(rsc.pendingCues, name)
+	rsc.setNextCue(name, index)
+	return nil
+}
@@ -0,0 +1,178 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func cueRequest(t *testing.T, rsc *Rosco, msgType MessageTypeRequest, req core.Marshaller) (*CueListStatus, *core.Error) {
+	t.Helper()
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(msgType), req)
+	if reply.Error != nil {
+		return nil, reply.Error
+	}
+	// every cue response is a status in field 1
+	resp := &CueStatusResponse{}
+	require.NoError(t, resp.UnmarshalVT(reply.GetMessage()))
+	return resp.GetStatus(), nil
+}
+
+func TestCueList(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts: map[int32]*Script{
+				1: {Name: "go", Actions: []*ScriptAction{
+					{Type: ScriptActionType_ActionTypeSet, Address: "/go", Values: float32Values(1)},
+				}},
+			},
+			Scenes: map[int32]*Scene{
+				1: {Name: "look", Values: []*SceneValue{
+					{Target: "lights", Address: "/dimmer", Values: float32Values(0.5)},
+				}},
+			},
+			CueLists: map[string]*CueList{
+				"show": {Cues: []*Cue{
+					{Subject: &Cue_ScriptId{ScriptId: 1}, Target: "mixer", Follow: CueFollow_FollowAutoContinue},
+					{Subject: &Cue_SceneId{SceneId: 1}, WaitMs: 100},
+					{Subject: &Cue_ScriptId{ScriptId: 1}, Target: "mixer"},
+				}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+
+	fh.now = 1000
+	status, busErr := cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 1, Next: 2, Pending: true}, status,
+		"cue 0 continues into cue 1, which waits",
+	)
+	tick(t, rsc, fh, 1001)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/go", values: float32Values(1)}}, fh.takeSent())
+
+	tick(t, rsc, fh, 1100)
+	require.Equal(t, []sentMessage{{target: "lights", address: "/dimmer", values: float32Values(0.5)}}, fh.takeSent())
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.False(t, status.GetPending())
+
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 2, Next: -1}, status)
+	_, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), busErr.GetCode())
+
+	// playback resumes where it was after a restart
+	rsc, err := newRosco(fh)
+	require.NoError(t, err)
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 2, Next: -1}, status)
+
+	fh.takeSent()
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_BACK_REQ, &CueBackRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 1, Next: 2, Pending: true}, status)
+	// GO runs a waiting cue immediately
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 1, Next: 2}, status)
+	require.Len(t, fh.takeSent(), 1)
+
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_JUMP_REQ, &CueJumpRequest{CueList: "show", Index: 0})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: -1, Next: 0}, status)
+	_, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_JUMP_REQ, &CueJumpRequest{CueList: "show", Index: 3})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), busErr.GetCode())
+	_, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "missing"})
+	require.Equal(t, int32(core.CommonErrorCode_NOT_FOUND), busErr.GetCode())
+}
+
+func TestCuePendingAcrossRestart(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scenes: map[int32]*Scene{
+				1: {Name: "look", Values: []*SceneValue{
+					{Target: "lights", Address: "/dimmer", Values: float32Values(0.5)},
+				}},
+			},
+			CueLists: map[string]*CueList{
+				"show": {Cues: []*Cue{
+					{Subject: &Cue_SceneId{SceneId: 1}, WaitMs: 500},
+					{Subject: &Cue_SceneId{SceneId: 1}},
+				}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+	fh.now = 1000
+	_, busErr := cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+	require.Nil(t, busErr)
+
+	// the plugin restarts while the cue is waiting
+	rsc, err := newRosco(fh)
+	require.NoError(t, err)
+	status, busErr := cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 0, Next: 1, Pending: true}, status)
+	tick(t, rsc, fh, 1499)
+	require.Empty(t, fh.takeSent())
+	tick(t, rsc, fh, 1500)
+	require.Equal(t, []sentMessage{{target: "lights", address: "/dimmer", values: float32Values(0.5)}}, fh.takeSent(),
+		"the waiting cue runs when it was due",
+	)
+
+	rsc, err = newRosco(fh)
+	require.NoError(t, err)
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, &CueListStatus{CueList: "show", Current: 0, Next: 1}, status, "a cue that has run isn't run again")
+}
+
+func TestCueRefusedByRunnerLimits(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	long := &Script{Name: "long", Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 10_000},
+	}}
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts: map[int32]*Script{1: long},
+			CueLists: map[string]*CueList{
+				"show": {Cues: []*Cue{
+					{Subject: &Cue_ScriptId{ScriptId: 1}, Target: "lights"},
+					{Subject: &Cue_ScriptId{ScriptId: 1}, Target: "mixer"},
+					{Subject: &Cue_ScriptId{ScriptId: 1}, Target: "mixer"},
+				}},
+			},
+			RunnerLimits: &RunnerLimits{MaxRunnersPerTarget: 1},
+		},
+	})
+	require.Nil(t, reply.Error)
+
+	fh.now = 1000
+	for i := 0; i < 2; i++ {
+		_, busErr := cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+		require.Nil(t, busErr)
+	}
+	want := &CueListStatus{CueList: "show", Current: 1, Next: 2}
+
+	_, busErr := cueRequest(t, rsc, MessageTypeRequest_CUE_GO_REQ, &CueGoRequest{CueList: "show"})
+	require.Equal(t, int32(ErrorCode_RESOURCE_EXHAUSTED), busErr.GetCode())
+	require.Equal(t, `1 scripts are running on target "mixer", the most allowed`, busErr.GetDetail())
+	status, busErr := cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, want, status, "a refused cue stays next")
+
+	_, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_BACK_REQ, &CueBackRequest{CueList: "show"})
+	require.Equal(t, int32(ErrorCode_RESOURCE_EXHAUSTED), busErr.GetCode())
+	status, busErr = cueRequest(t, rsc, MessageTypeRequest_CUE_STATUS_REQ, &CueStatusRequest{CueList: "show"})
+	require.Nil(t, busErr)
+	require.Equal(t, want, status, "a refused back leaves the cue list where it was")
+}
@@ -0,0 +1,149 @@
+package rosco
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// exportDocument builds a document with the selected scripts and triggers
+// from cfg, including the scripts the triggers run. If nothing is selected
+// everything is exported. An error is returned if a selected script
+// or trigger doesn't exist.
+func exportDocument(cfg *Config, scriptIDs []int32, triggerIDs []string) (*ExportDocument, error) {
+	doc := &ExportDocument{
+		SchemaVersion: currentSchemaVersion,
+		Scripts:       map[int32]*Script{},
+		Triggers:      map[string]*Trigger{},
+	}
+	if len(scriptIDs) == 0 && len(triggerIDs) == 0 {
+		for id := range cfg.GetScripts() {
+			scriptIDs = append(scriptIDs, id)
+		}
+		for id := range cfg.GetTriggers() {
+			triggerIDs = append(triggerIDs, id)
+		}
+	}
+	for _, id := range triggerIDs {
+		trigger, present := cfg.GetTriggers()[id]
+		if !present {
+			return nil, fmt.Errorf("no trigger %q", id)
+		}
+		doc.Triggers[id] = trigger
+		if trigger.runsScript() {
+			scriptIDs = append(scriptIDs, trigger.GetScriptId())
+		}
+	}
+	for _, id := range scriptIDs {
+		script, present := cfg.GetScripts()[id]
+		if !present {
+			return nil, fmt.Errorf("no script %d", id)
+		}
+		doc.Scripts[id] = script
+	}
+	return doc, nil
+}
+
+// importDocument adds the scripts and triggers in doc to a copy of cfg,
+// returning the new config. Imported scripts are given new IDs and imported
+// triggers are updated to match. A script with the same name as an existing
+// script isn't imported and triggers referencing it use the existing script
+// instead. Unnamed scripts can't be matched by name, so each is imported as
+// a script of its own. Panic and tap tempo triggers are imported as they
+// are. A trigger with the same ID as an existing trigger isn't imported.
+// Both cases are reported as conflicts. If any imported script or trigger is
+// invalid, or the config as a whole would be, the problems are listed in the
+// response's errors and the returned config is nil.
+func importDocument(cfg *Config, doc *ExportDocument) (*Config, *ImportResponse, error) {
+	imported := &Config{
+		Scripts:       doc.GetScripts(),
+		Triggers:      doc.GetTriggers(),
+		SchemaVersion: doc.GetSchemaVersion(),
+	}
+	if err := migrateConfig(imported); err != nil {
+		return nil, nil, fmt.Errorf("migrating document: %w", err)
+	}
+
+	newCfg := cfg.CloneVT()
+	if newCfg.Scripts == nil {
+		newCfg.Scripts = map[int32]*Script{}
+	}
+	if newCfg.Triggers == nil {
+		newCfg.Triggers = map[string]*Trigger{}
+	}
+	resp := &ImportResponse{
+		ScriptIds: map[int32]int32{},
+	}
+	scriptsByName := map[string]int32{}
+	for id, script := range newCfg.GetScripts() {
+		if script.GetName() != "" {
+			scriptsByName[script.GetName()] = id
+		}
+	}
+	// document script ID -> config script ID, including conflicting scripts
+	resolved := map[int32]int32{}
+	docScriptIDs := make([]int32, 0, len(imported.GetScripts()))
+	for id := range imported.GetScripts() {
+		docScriptIDs = append(docScriptIDs, id)
+	}
+	slices.Sort(docScriptIDs)
+	for _, docID := range docScriptIDs {
+		script := imported.GetScripts()[docID]
+		if existingID, present := scriptsByName[script.GetName()]; present {
+			resolved[docID] = existingID
+			resp.Conflicts = append(resp.Conflicts, &ImportConflict{
+				Subject: &ImportConflict_ScriptName{ScriptName: script.GetName()},
+			})
+			continue
+		}
+		id := nextScriptID(newCfg)
+		resp.Errors = append(resp.Errors, validateScript(docID, script)...)
+		resp.Errors = append(resp.Errors, validateScriptSize(docID, script, newCfg.GetRunnerLimits())...)
+		newCfg.Scripts[id] = script
+		if script.GetName() != "" {
+			scriptsByName[script.GetName()] = id
+		}
+		resolved[docID] = id
+		resp.ScriptIds[docID] = id
+	}
+
+	triggerIDs := make([]string, 0, len(imported.GetTriggers()))
+	for id := range imported.GetTriggers() {
+		triggerIDs = append(triggerIDs, id)
+	}
+	sort.Strings(triggerIDs)
+	for _, id := range triggerIDs {
+		if _, present := newCfg.GetTriggers()[id]; present {
+			resp.Conflicts = append(resp.Conflicts, &ImportConflict{
+				Subject: &ImportConflict_TriggerId{TriggerId: id},
+			})
+			continue
+		}
+		trigger := imported.GetTriggers()[id].CloneVT()
+		if trigger.runsScript() {
+			scriptID, present := resolved[trigger.GetScriptId()]
+			if !present {
+				resp.Errors = append(resp.Errors, &ConfigError{
+					Subject:     &ConfigError_TriggerId{TriggerId: id},
+					ActionIndex: -1,
+					Message:     fmt.Sprintf("trigger references script %d not in the document", trigger.GetScriptId()),
+				})
+				continue
+			}
+			trigger.ScriptId = scriptID
+		}
+		resp.Errors = append(resp.Errors, validateTrigger(newCfg, id, trigger)...)
+		newCfg.Triggers[id] = trigger
+		resp.TriggerIds = append(resp.TriggerIds, id)
+	}
+
+	if len(resp.Errors) > 0 {
+		return nil, resp, nil
+	}
+	// what's imported is checked above against the document's IDs, but the
+	// config as a whole must still be valid with it added
+	if resp.Errors = validateConfig(newCfg); len(resp.Errors) > 0 {
+		return nil, resp, nil
+	}
+	return newCfg, resp, nil
+}
@@ -0,0 +1,258 @@
+package rosco
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// exportConfig exports everything in rsc's config
+func exportConfig(t *testing.T, rsc *Rosco) string {
+	t.Helper()
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_EXPORT_REQ), &ExportRequest{})
+	require.Nil(t, reply.Error)
+	er := &ExportResponse{}
+	require.NoError(t, er.UnmarshalVT(reply.GetMessage()))
+	return er.GetDocument()
+}
+
+func TestExportImportPanicAndTapTriggers(t *testing.T) {
+	t.Parallel()
+	from, _ := newTestRosco(t)
+	reply := busRequest(t, from, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts: map[int32]*Script{5: {Name: "show", Actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1},
+			}}},
+			Triggers: map[string]*Trigger{
+				"go":   {Target: "mixer", ScriptId: 5},
+				"stop": {Panic: true},
+				"tap":  {TapTempo: true, Group: "show"},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+	doc := exportConfig(t, from)
+
+	to, _ := newTestRosco(t)
+	reply = busRequest(t, to, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts: map[int32]*Script{1: {Name: "other"}},
+		},
+	})
+	require.Nil(t, reply.Error)
+	reply = busRequest(t, to, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_IMPORT_REQ), &ImportRequest{
+		Document:     doc,
+		BaseRevision: to.cfg.GetRevision(),
+	})
+	require.Nil(t, reply.Error)
+	ir := &ImportResponse{}
+	require.NoError(t, ir.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, map[int32]int32{5: 2}, ir.GetScriptIds())
+	require.Equal(t, []string{"go", "stop", "tap"}, ir.GetTriggerIds())
+	require.Equal(t, map[string]*Trigger{
+		"go":   {Target: "mixer", ScriptId: 2},
+		"stop": {Panic: true},
+		"tap":  {TapTempo: true, Group: "show"},
+	}, to.cfg.GetTriggers(), "panic and tap triggers come through as they are")
+}
+
+func TestImportUnnamedScripts(t *testing.T) {
+	t.Parallel()
+	sleep := func(ms uint32) *Script {
+		return &Script{Actions: []*ScriptAction{{Type: ScriptActionType_ActionTypeSleep, DurationMs: ms}}}
+	}
+	from, _ := newTestRosco(t)
+	reply := busRequest(t, from, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts: map[int32]*Script{1: sleep(1), 2: sleep(2)},
+			Triggers: map[string]*Trigger{
+				"one": {Target: "mixer", ScriptId: 1},
+				"two": {Target: "mixer", ScriptId: 2},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+	doc := exportConfig(t, from)
+
+	to, _ := newTestRosco(t)
+	reply = busRequest(t, to, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{Scripts: map[int32]*Script{1: sleep(3)}},
+	})
+	require.Nil(t, reply.Error)
+	reply = busRequest(t, to, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_IMPORT_REQ), &ImportRequest{
+		Document:     doc,
+		BaseRevision: to.cfg.GetRevision(),
+	})
+	require.Nil(t, reply.Error)
+	ir := &ImportResponse{}
+	require.NoError(t, ir.UnmarshalVT(reply.GetMessage()))
+	require.Empty(t, ir.GetConflicts(), "unnamed scripts don't conflict with each other")
+	require.Equal(t, map[int32]int32{1: 2, 2: 3}, ir.GetScriptIds())
+	require.Equal(t, uint32(1), to.cfg.GetScripts()[to.cfg.GetTriggers()["one"].GetScriptId()].GetActions()[0].GetDurationMs())
+	require.Equal(t, uint32(2), to.cfg.GetScripts()[to.cfg.GetTriggers()["two"].GetScriptId()].GetActions()[0].GetDurationMs())
+}
+
+func TestExportDocument(t *testing.T) {
+	t.Parallel()
+	cfg := &Config{
+		Scripts: map[int32]*Script{1: {Name: "one"}, 2: {Name: "two"}, 3: {Name: "three"}},
+		Triggers: map[string]*Trigger{
+			"go":   {Target: "mixer", ScriptId: 2},
+			"stop": {Panic: true},
+		},
+	}
+	for _, tc := range []struct {
+		name       string
+		scriptIDs  []int32
+		triggerIDs []string
+		scripts    []int32
+		triggers   []string
+		err        string
+	}{
+		{
+			name:     "everything",
+			scripts:  []int32{1, 2, 3},
+			triggers: []string{"go", "stop"},
+		},
+		{
+			name:      "selected script",
+			scriptIDs: []int32{3},
+			scripts:   []int32{3},
+		},
+		{
+			name:       "triggers bring their scripts",
+			scriptIDs:  []int32{1},
+			triggerIDs: []string{"go", "stop"},
+			scripts:    []int32{1, 2},
+			triggers:   []string{"go", "stop"},
+		},
+		{
+			name:      "missing script",
+			scriptIDs: []int32{4},
+			err:       "no script 4",
+		},
+		{
+			name:       "missing trigger",
+			triggerIDs: []string{"nope"},
+			err:        `no trigger "nope"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			doc, err := exportDocument(cfg, tc.scriptIDs, tc.triggerIDs)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, currentSchemaVersion, doc.GetSchemaVersion())
+			require.ElementsMatch(t, tc.scripts, slices.Collect(maps.Keys(doc.GetScripts())))
+			require.ElementsMatch(t, tc.triggers, slices.Collect(maps.Keys(doc.GetTriggers())))
+		})
+	}
+}
+
+func TestImportDocument(t *testing.T) {
+	t.Parallel()
+	sleep := &ScriptAction{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1}
+	cfg := &Config{
+		Scripts:  map[int32]*Script{1: {Name: "existing", Actions: []*ScriptAction{sleep}}},
+		Triggers: map[string]*Trigger{"taken": {Target: "mixer", ScriptId: 1}},
+	}
+	for _, tc := range []struct {
+		name      string
+		doc       *ExportDocument
+		scriptIDs map[int32]int32
+		triggers  map[string]*Trigger
+		conflicts []*ImportConflict
+		errs      []string
+		err       string
+	}{
+		{
+			name: "scripts get new IDs and triggers follow",
+			doc: &ExportDocument{
+				Scripts: map[int32]*Script{1: {Name: "a", Actions: []*ScriptAction{sleep}}, 7: {Name: "b", Actions: []*ScriptAction{sleep}}},
+				Triggers: map[string]*Trigger{
+					"a": {Target: "mixer", ScriptId: 1},
+					"b": {Target: "mixer", ScriptId: 7},
+				},
+			},
+			scriptIDs: map[int32]int32{1: 2, 7: 3},
+			triggers: map[string]*Trigger{
+				"a": {Target: "mixer", ScriptId: 2},
+				"b": {Target: "mixer", ScriptId: 3},
+			},
+		},
+		{
+			name: "a script with an existing name is reused",
+			doc: &ExportDocument{
+				Scripts:  map[int32]*Script{5: {Name: "existing"}},
+				Triggers: map[string]*Trigger{"again": {Target: "lights", ScriptId: 5}},
+			},
+			scriptIDs: map[int32]int32{},
+			triggers:  map[string]*Trigger{"again": {Target: "lights", ScriptId: 1}},
+			conflicts: []*ImportConflict{{Subject: &ImportConflict_ScriptName{ScriptName: "existing"}}},
+		},
+		{
+			name: "an existing trigger ID isn't imported",
+			doc: &ExportDocument{
+				Scripts:  map[int32]*Script{1: {Name: "new", Actions: []*ScriptAction{sleep}}},
+				Triggers: map[string]*Trigger{"taken": {Target: "lights", ScriptId: 1}},
+			},
+			scriptIDs: map[int32]int32{1: 2},
+			triggers:  map[string]*Trigger{},
+			conflicts: []*ImportConflict{{Subject: &ImportConflict_TriggerId{TriggerId: "taken"}}},
+		},
+		{
+			name: "a trigger's script must be in the document",
+			doc: &ExportDocument{
+				Triggers: map[string]*Trigger{"lost": {Target: "mixer", ScriptId: 1}},
+			},
+			errs: []string{`trigger "lost": trigger references script 1 not in the document`},
+		},
+		{
+			name: "invalid scripts are reported",
+			doc: &ExportDocument{
+				Scripts: map[int32]*Script{3: {Name: "bad", Actions: []*ScriptAction{
+					{Type: ScriptActionType_ActionTypeFade, Address: "/a", DurationMs: 1},
+				}}},
+			},
+			errs: []string{"script 3 action 0: fade needs 2 values, has 0"},
+		},
+		{
+			name: "newer documents are refused",
+			doc:  &ExportDocument{SchemaVersion: currentSchemaVersion + 1},
+			err:  "migrating document: config schema version 2 is newer than supported version 1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			newCfg, resp, err := importDocument(cfg, tc.doc)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			if len(tc.errs) > 0 {
+				require.Nil(t, newCfg)
+				var errs []string
+				for _, ce := range resp.GetErrors() {
+					errs = append(errs, ce.describe())
+				}
+				require.Equal(t, tc.errs, errs)
+				return
+			}
+			require.Empty(t, resp.GetErrors())
+			require.Equal(t, tc.scriptIDs, resp.GetScriptIds())
+			require.Equal(t, tc.conflicts, resp.GetConflicts())
+			for id, trigger := range tc.triggers {
+				require.Equal(t, trigger, newCfg.GetTriggers()[id], id)
+			}
+			require.Len(t, newCfg.GetTriggers(), len(cfg.GetTriggers())+len(tc.triggers))
+			require.Len(t, cfg.GetScripts(), 1, "the original config isn't changed")
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package rosco
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	// how many config revisions to keep
+	historySize = 20
+)
+
+var (
+	historyKVPrefix = []byte("history-")
+)
+
+// historyKVKey is the key for a saved config revision. Revisions are zero
+// padded so keys sort in revision order.
+func historyKVKey(revision uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", historyKVPrefix, revision))
+}
+
+// historyKeys lists the keys of saved config revisions, oldest first
+func (rsc *Rosco) historyKeys() ([]string, error) {
+	kvKeys, err := rsc.host.KVList(historyKVPrefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(kvKeys))
+	for i, key := range kvKeys {
+		keys[i] = string(key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// recordHistory saves the current config as a revision, dropping the oldest
+// revisions beyond historySize
+func (rsc *Rosco) recordHistory(comment string) {
+	now, err := rsc.host.CurrentTimeMillis()
+	if err != nil {
+		rsc.host.LogError("getting current time", "error", err.Error())
+	}
+	cr := &ConfigRevision{
+		Revision:    rsc.cfg.GetRevision(),
+		TimestampMs: now,
+		Comment:     comment,
+		Config:      rsc.cfg,
+	}
+	if err := rsc.kvSetProto(historyKVKey(cr.Revision), cr); err != nil {
+		rsc.host.LogError("writing config history", "error", err.Error())
+		return
+	}
+
+	keys, err := rsc.historyKeys()
+	if err != nil {
+		rsc.host.LogError("listing config history", "error", err.Error())
+		return
+	}
+	for len(keys) > historySize {
+		if err := rsc.host.KVDelete([]byte(keys[0])); err != nil {
+			rsc.host.LogError("deleting config history", "key", keys[0], "error", err.Error())
+		}
+		keys = keys[1:]
+	}
+}
+
+// loadRevision retrieves a saved config revision
+func (rsc *Rosco) loadRevision(revision uint64) (*ConfigRevision, error) {
+	cr := &ConfigRevision{}
+	if err := rsc.kvGetProto(historyKVKey(revision), cr); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// diffConfigs lists the scripts, triggers, trigger groups, scenes, cue lists,
+// masters, safe states, limits, target groups, and outputs that differ
+// between from and to, then whether the runner limits differ
+func diffConfigs(from, to *Config) []*ConfigChange {
+	var changes []*ConfigChange
+	changes = append(changes, diffMaps(from.GetScripts(), to.GetScripts(), func(id int32) isConfigChange_Subject {
+		return &ConfigChange_ScriptId{ScriptId: id}
+	})...)
+	changes = append(changes, diffMaps(from.GetTriggers(), to.GetTriggers(), func(id string) isConfigChange_Subject {
+		return &ConfigChange_TriggerId{TriggerId: id}
+	})...)
+	changes = append(changes, diffMaps(from.GetTriggerGroups(), to.GetTriggerGroups(), func(name string) isConfigChange_Subject {
+		return &ConfigChange_TriggerGroup{TriggerGroup: name}
+	})...)
+	changes = append(changes, diffMaps(from.GetScenes(), to.GetScenes(), func(id int32) isConfigChange_Subject {
+		return &ConfigChange_SceneId{SceneId: id}
+	})...)
+	changes = append(changes, diffMaps(from.GetCueLists(), to.GetCueLists(), func(name string) isConfigChange_Subject {
+		return &ConfigChange_CueList{CueList: name}
+	})...)
+	changes = append(changes, diffMaps(from.GetMasters(), to.GetMasters(), func(name string) isConfigChange_Subject {
+		return &ConfigChange_Master{Master: name}
+	})...)
+	changes = append(changes, diffMaps(from.GetSafeStates(), to.GetSafeStates(), func(target string) isConfigChange_Subject {
+		return &ConfigChange_SafeState{SafeState: target}
+	})...)
+	changes = append(changes, diffMaps(from.GetLimits(), to.GetLimits(), func(target string) isConfigChange_Subject {
+		return &ConfigChange_Limits{Limits: target}
+	})...)
+	changes = append(changes, diffMaps(from.GetTargetGroups(), to.GetTargetGroups(), func(name string) isConfigChange_Subject {
+		return &ConfigChange_TargetGroup{TargetGroup: name}
+	})...)
+	changes = append(changes, diffMaps(from.GetOutputs(), to.GetOutputs(), func(target string) isConfigChange_Subject {
+		return &ConfigChange_Output{Output: target}
+	})...)
+
+	fromLimits, toLimits := from.GetRunnerLimits(), to.GetRunnerLimits()
+	if change, changed := changeType(fromLimits != nil, toLimits != nil, fromLimits.EqualVT(toLimits)); changed {
+		changes = append(changes, &ConfigChange{
+			Subject: &ConfigChange_RunnerLimits{RunnerLimits: true},
+			Change:  change,
+		})
+	}
+
+	return changes
+}
+
+// diffMaps lists the entries added, removed, or changed between from and to,
+// in key order, with the subject of each change made by subject
+func diffMaps[K cmp.Ordered, V interface{ EqualVT(V) bool }](from, to map[K]V, subject func(K) isConfigChange_Subject) []*ConfigChange {
+	var changes []*ConfigChange
+	for _, key := range unionKeys(from, to) {
+		fromValue, inFrom := from[key]
+		toValue, inTo := to[key]
+		if change, changed := changeType(inFrom, inTo, fromValue.EqualVT(toValue)); changed {
+			changes = append(changes, &ConfigChange{
+				Subject: subject(key),
+				Change:  change,
+			})
+		}
+	}
+	return changes
+}
+
+// changeType is the kind of change made to something that's inFrom and inTo,
+// or false if it's equal in both, including when it's in neither
+func changeType(inFrom, inTo, equal bool) (ConfigChangeType, bool) {
+	switch {
+	case equal:
+		return 0, false
+	case !inFrom:
+		return ConfigChangeType_CHANGE_ADDED, true
+	case !inTo:
+		return ConfigChangeType_CHANGE_REMOVED, true
+	}
+	return ConfigChangeType_CHANGE_CHANGED, true
+}
+
+// unionKeys returns the sorted keys present in either a or b
+func unionKeys[K cmp.Ordered, V any](a, b map[K]V) []K {
+	keys := make([]K, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, present := a[k]; !present {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,179 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	t.Parallel()
+	base := &Config{
+		Scripts:       map[int32]*Script{1: {Name: "one"}, 2: {Name: "two"}},
+		Triggers:      map[string]*Trigger{"go": {Target: "mixer", ScriptId: 1}},
+		TriggerGroups: map[string]*TriggerGroup{"show": {Armed: true}},
+		Masters:       map[string]*Master{"grand": {}},
+		RunnerLimits:  &RunnerLimits{MaxRunners: 4},
+	}
+	for _, tc := range []struct {
+		name    string
+		change  func(cfg *Config)
+		changes []*ConfigChange
+	}{
+		{
+			name:   "unchanged",
+			change: func(cfg *Config) {},
+		},
+		{
+			name: "revision only",
+			change: func(cfg *Config) {
+				cfg.Revision = 7
+			},
+		},
+		{
+			name: "script added, removed and changed",
+			change: func(cfg *Config) {
+				cfg.Scripts[3] = &Script{Name: "three"}
+				delete(cfg.Scripts, 2)
+				cfg.Scripts[1].Name = "uno"
+			},
+			changes: []*ConfigChange{
+				{Subject: &ConfigChange_ScriptId{ScriptId: 1}, Change: ConfigChangeType_CHANGE_CHANGED},
+				{Subject: &ConfigChange_ScriptId{ScriptId: 2}, Change: ConfigChangeType_CHANGE_REMOVED},
+				{Subject: &ConfigChange_ScriptId{ScriptId: 3}, Change: ConfigChangeType_CHANGE_ADDED},
+			},
+		},
+		{
+			name: "each kind in order",
+			change: func(cfg *Config) {
+				cfg.Outputs = map[string]*TargetOutput{"mixer": {Fps: 30}}
+				cfg.Masters = nil
+				cfg.TriggerGroups["show"].Armed = false
+				cfg.Triggers["go"].Disabled = true
+				cfg.Scenes = map[int32]*Scene{1: {Name: "look"}}
+			},
+			changes: []*ConfigChange{
+				{Subject: &ConfigChange_TriggerId{TriggerId: "go"}, Change: ConfigChangeType_CHANGE_CHANGED},
+				{Subject: &ConfigChange_TriggerGroup{TriggerGroup: "show"}, Change: ConfigChangeType_CHANGE_CHANGED},
+				{Subject: &ConfigChange_SceneId{SceneId: 1}, Change: ConfigChangeType_CHANGE_ADDED},
+				{Subject: &ConfigChange_Master{Master: "grand"}, Change: ConfigChangeType_CHANGE_REMOVED},
+				{Subject: &ConfigChange_Output{Output: "mixer"}, Change: ConfigChangeType_CHANGE_ADDED},
+			},
+		},
+		{
+			name: "runner limits changed",
+			change: func(cfg *Config) {
+				cfg.RunnerLimits.MaxRunners = 8
+			},
+			changes: []*ConfigChange{
+				{Subject: &ConfigChange_RunnerLimits{RunnerLimits: true}, Change: ConfigChangeType_CHANGE_CHANGED},
+			},
+		},
+		{
+			name: "runner limits removed",
+			change: func(cfg *Config) {
+				cfg.RunnerLimits = nil
+			},
+			changes: []*ConfigChange{
+				{Subject: &ConfigChange_RunnerLimits{RunnerLimits: true}, Change: ConfigChangeType_CHANGE_REMOVED},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			to := base.CloneVT()
+			tc.change(to)
+			require.Equal(t, tc.changes, diffConfigs(base, to))
+		})
+	}
+}
+
+func TestConfigHistory(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	for _, name := range []string{"one", "two", "three"} {
+		reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+			Config:  &Config{Revision: rsc.cfg.GetRevision(), Scripts: map[int32]*Script{1: {Name: name}}},
+			Comment: "named " + name,
+		})
+		require.Nil(t, reply.Error)
+	}
+
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_CONFIG_HISTORY_LIST_REQ), &ConfigHistoryListRequest{})
+	require.Nil(t, reply.Error)
+	chlr := &ConfigHistoryListResponse{}
+	require.NoError(t, chlr.UnmarshalVT(reply.GetMessage()))
+	var comments []string
+	for _, cr := range chlr.GetRevisions() {
+		require.Nil(t, cr.GetConfig(), "listed revisions don't include the config")
+		comments = append(comments, cr.GetComment())
+	}
+	require.Equal(t, []string{"named three", "named two", "named one"}, comments)
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_CONFIG_HISTORY_DIFF_REQ), &ConfigHistoryDiffRequest{
+		FromRevision: 1,
+		ToRevision:   3,
+	})
+	require.Nil(t, reply.Error)
+	chdr := &ConfigHistoryDiffResponse{}
+	require.NoError(t, chdr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, []*ConfigChange{
+		{Subject: &ConfigChange_ScriptId{ScriptId: 1}, Change: ConfigChangeType_CHANGE_CHANGED},
+	}, chdr.GetChanges())
+
+	for _, tc := range []struct {
+		name     string
+		req      *ConfigRestoreRequest
+		code     int32
+		revision uint64
+		script   string
+	}{
+		{
+			name: "stale base revision",
+			req:  &ConfigRestoreRequest{Revision: 1, BaseRevision: 2},
+			code: int32(ErrorCode_CONFLICT),
+		},
+		{
+			name: "missing revision",
+			req:  &ConfigRestoreRequest{Revision: 9, BaseRevision: 3},
+			code: int32(core.CommonErrorCode_NOT_FOUND),
+		},
+		{
+			name:     "restored as a new revision",
+			req:      &ConfigRestoreRequest{Revision: 1, BaseRevision: 3},
+			revision: 4,
+			script:   "one",
+		},
+		{
+			name:     "restoring the restore",
+			req:      &ConfigRestoreRequest{Revision: 3, BaseRevision: 4},
+			revision: 5,
+			script:   "three",
+		},
+	} {
+		reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_RESTORE_REQ), tc.req)
+		if tc.code != 0 {
+			require.Equal(t, tc.code, reply.Error.GetCode(), tc.name)
+			continue
+		}
+		require.Nil(t, reply.Error, tc.name)
+		require.Equal(t, tc.revision, rsc.cfg.GetRevision(), tc.name)
+		require.Equal(t, tc.script, rsc.cfg.GetScripts()[1].GetName(), tc.name)
+	}
+}
+
+func TestConfigHistoryPruned(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	for i := 0; i < historySize+5; i++ {
+		reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+			Config: &Config{Revision: rsc.cfg.GetRevision()},
+		})
+		require.Nil(t, reply.Error)
+	}
+	keys, err := fh.KVList(historyKVPrefix)
+	require.NoError(t, err)
+	require.Len(t, keys, historySize)
+	require.Equal(t, historyKVKey(6), keys[0], "the oldest revisions are dropped")
+}
@@ -0,0 +1,148 @@
+package rosco
+
+import (
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/autonomouskoi/core-tinygo/svc"
+)
+
+// host is everything Rosco needs from the plugin host: the bus, the KV store,
+// logging, and sending OSC. Rosco only talks to the host through this
+// interface so tests can substitute a fake.
+type host interface {
+	Send(msg *core.BusMessage) error
+	Subscribe(topic string) error
+	TimeNotifyEvery(ms uint64) (int64, error)
+	CurrentTimeMillis() (int64, error)
+
+	// KVGet returns akcore.ErrNotFound if key isn't present
+	KVGet(key []byte) ([]byte, error)
+	KVSet(key, value []byte) error
+	KVList(prefix []byte) ([][]byte, error)
+	KVDelete(key []byte) error
+
+	LogDebug(message string, args ...any)
+	LogInfo(message string, args ...any)
+	LogError(message string, args ...any)
+	LogBusError(message string, err *core.Error)
+
+	SendOSC(target, address string, values []*OSCValue) error
+}
+
+// coreHost is the host as provided by core-tinygo
+type coreHost struct{}
+
+func (coreHost) Send(msg *core.BusMessage) error {
+	return core.Send(msg)
+}
+
+func (coreHost) Subscribe(topic string) error {
+	return core.Subscribe(topic)
+}
+
+func (coreHost) TimeNotifyEvery(ms uint64) (int64, error) {
+	return svc.TimeNotifyEvery(ms)
+}
+
+func (coreHost) CurrentTimeMillis() (int64, error) {
+	now, _, err := svc.CurrentTimeMillis()
+	return now, err
+}
+
+func (coreHost) KVGet(key []byte) ([]byte, error) {
+	return core.KVGet(key)
+}
+
+func (coreHost) KVSet(key, value []byte) error {
+	return core.KVSet(key, value)
+}
+
+func (coreHost) KVList(prefix []byte) ([][]byte, error) {
+	resp, err := core.KVList(prefix, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetKeys(), nil
+}
+
+func (coreHost) KVDelete(key []byte) error {
+	return core.KVDelete(key)
+}
+
+func (coreHost) LogDebug(message string, args ...any) {
+	core.LogDebug(message, args...)
+}
+
+func (coreHost) LogInfo(message string, args ...any) {
+	core.LogInfo(message, args...)
+}
+
+func (coreHost) LogError(message string, args ...any) {
+	core.LogError(message, args...)
+}
+
+func (coreHost) LogBusError(message string, err *core.Error) {
+	core.LogBusError(message, err)
+}
+
+func (coreHost) SendOSC(target, address string, values []*OSCValue) error {
+	svcValues := make([]*svc.OSCValue, len(values))
+	for i, v := range values {
+		sv := &svc.OSCValue{}
+		switch vv := v.Value.(type) {
+		case *OSCValue_Nil:
+			sv.Value = &svc.OSCValue_Nil{Nil: vv.Nil}
+		case *OSCValue_Int32:
+			sv.Value = &svc.OSCValue_Int32{Int32: vv.Int32}
+		case *OSCValue_Float32:
+			sv.Value = &svc.OSCValue_Float32{Float32: vv.Float32}
+		case *OSCValue_String_:
+			sv.Value = &svc.OSCValue_String_{String_: vv.String_}
+		case *OSCValue_Blob:
+			sv.Value = &svc.OSCValue_Blob{Blob: vv.Blob}
+		case *OSCValue_Int64:
+			sv.Value = &svc.OSCValue_Int64{Int64: vv.Int64}
+		case *OSCValue_True:
+			sv.Value = &svc.OSCValue_True{True: vv.True}
+		case *OSCValue_False:
+			sv.Value = &svc.OSCValue_False{False: vv.False}
+		}
+		svcValues[i] = sv
+	}
+	sr := svc.OSCSendMessageRequest{
+		TargetName: target,
+		Address:    address,
+		Values:     svcValues,
+	}
+	msg := &core.BusMessage{
+		Type: int32(svc.MessageType_OSC_SEND_MESSAGE_REQ),
+	}
+	if core.MarshalMessage(msg, &sr); msg.Error != nil {
+		return msg.Error
+	}
+	reply, err := core.WaitForReply(msg, 1000)
+	if err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	return nil
+}
+
+// kvGetProto retrieves key from the host's KV store into p
+func (rsc *Rosco) kvGetProto(key []byte, p core.Unmarshaller) error {
+	b, err := rsc.host.KVGet(key)
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalVT(b)
+}
+
+// kvSetProto stores p in the host's KV store as key
+func (rsc *Rosco) kvSetProto(key []byte, p core.Marshaller) error {
+	b, err := p.MarshalVT()
+	if err != nil {
+		return err
+	}
+	return rsc.host.KVSet(key, b)
+}
@@ -0,0 +1,194 @@
+package rosco
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/autonomouskoi/akcore"
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/autonomouskoi/core-tinygo/svc"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMessage struct {
+	target, address string
+	values          []*OSCValue
+}
+
+type logEntry struct {
+	level, message string
+	args           []any
+}
+
+// fakeHost is an in-memory host for tests
+type fakeHost struct {
+	now        int64
+	kv         map[string][]byte
+	subscribed []string
+	published  []*core.BusMessage
+	sent       []sentMessage
+	logs       []logEntry
+	// kvSetErr is returned by KVSet if it's set
+	kvSetErr error
+}
+
+func newFakeHost() *fakeHost {
+	return &fakeHost{
+		now: 1,
+		kv:  map[string][]byte{},
+	}
+}
+
+func (fh *fakeHost) Send(msg *core.BusMessage) error {
+	fh.published = append(fh.published, msg)
+	return nil
+}
+
+func (fh *fakeHost) Subscribe(topic string) error {
+	fh.subscribed = append(fh.subscribed, topic)
+	return nil
+}
+
+func (fh *fakeHost) TimeNotifyEvery(ms uint64) (int64, error) {
+	return 1, nil
+}
+
+func (fh *fakeHost) CurrentTimeMillis() (int64, error) {
+	return fh.now, nil
+}
+
+func (fh *fakeHost) KVGet(key []byte) ([]byte, error) {
+	value, present := fh.kv[string(key)]
+	if !present {
+		return nil, akcore.ErrNotFound
+	}
+	return value, nil
+}
+
+func (fh *fakeHost) KVSet(key, value []byte) error {
+	if fh.kvSetErr != nil {
+		return fh.kvSetErr
+	}
+	fh.kv[string(key)] = bytes.Clone(value)
+	return nil
+}
+
+func (fh *fakeHost) KVList(prefix []byte) ([][]byte, error) {
+	var keys []string
+	for key := range fh.kv {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	matches := make([][]byte, len(keys))
+	for i, key := range keys {
+		matches[i] = []byte(key)
+	}
+	return matches, nil
+}
+
+func (fh *fakeHost) KVDelete(key []byte) error {
+	delete(fh.kv, string(key))
+	return nil
+}
+
+func (fh *fakeHost) LogDebug(message string, args ...any) {
+	fh.logs = append(fh.logs, logEntry{level: "debug", message: message, args: args})
+}
+
+func (fh *fakeHost) LogInfo(message string, args ...any) {
+	fh.logs = append(fh.logs, logEntry{level: "info", message: message, args: args})
+}
+
+func (fh *fakeHost) LogError(message string, args ...any) {
+	fh.logs = append(fh.logs, logEntry{level: "error", message: message, args: args})
+}
+
+func (fh *fakeHost) LogBusError(message string, err *core.Error) {
+	fh.LogError(message, "error", err.Error())
+}
+
+func (fh *fakeHost) SendOSC(target, address string, values []*OSCValue) error {
+	fh.sent = append(fh.sent, sentMessage{target: target, address: address, values: values})
+	return nil
+}
+
+// takeSent returns the messages sent since the last call
+func (fh *fakeHost) takeSent() []sentMessage {
+	sent := fh.sent
+	fh.sent = nil
+	return sent
+}
+
+func newTestRosco(t *testing.T) (*Rosco, *fakeHost) {
+	t.Helper()
+	fh := newFakeHost()
+	rsc, err := newRosco(fh)
+	require.NoError(t, err)
+	return rsc, fh
+}
+
+// busRequest sends req to the handler for topic and msgType, returning the
+// reply
+func busRequest(t *testing.T, rsc *Rosco, topic BusTopic, msgType int32, req core.Marshaller) *core.BusMessage {
+	t.Helper()
+	msg := &core.BusMessage{
+		Topic: topic.String(),
+		Type:  msgType,
+	}
+	core.MarshalMessage(msg, req)
+	require.Nil(t, msg.Error)
+	reply := rsc.router[msg.Topic].Handle(msg)
+	require.NotNil(t, reply, "no reply")
+	require.Equal(t, msgType+1, reply.Type)
+	return reply
+}
+
+// tick delivers a time notification for now
+func tick(t *testing.T, rsc *Rosco, fh *fakeHost, now int64) {
+	t.Helper()
+	fh.now = now
+	msg := &core.BusMessage{
+		Type: int32(svc.MessageType_TIME_NOTIFICATION_EVENT),
+	}
+	core.MarshalMessage(msg, &svc.TimeNotification{CurrentTimeMillis: now})
+	require.Nil(t, msg.Error)
+	require.Nil(t, rsc.handleDirect().Handle(msg))
+}
+
+func float32Values(vs ...float32) []*OSCValue {
+	values := make([]*OSCValue, len(vs))
+	for i, v := range vs {
+		values[i] = &OSCValue{Value: &OSCValue_Float32{Float32: v}}
+	}
+	return values
+}
+
+func TestNewSubscribes(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	require.Len(t, fh.subscribed, len(rsc.router))
+	for _, topic := range fh.subscribed {
+		require.Contains(t, rsc.router, topic)
+	}
+}
+
+func TestFakeHostKV(t *testing.T) {
+	t.Parallel()
+	fh := newFakeHost()
+	_, err := fh.KVGet([]byte("missing"))
+	require.ErrorIs(t, err, akcore.ErrNotFound)
+	for i := 3; i > 0; i-- {
+		require.NoError(t, fh.KVSet([]byte(fmt.Sprintf("k-%d", i)), []byte{byte(i)}))
+	}
+	require.NoError(t, fh.KVSet([]byte("other"), nil))
+	keys, err := fh.KVList([]byte("k-"))
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("k-1"), []byte("k-2"), []byte("k-3")}, keys)
+	require.NoError(t, fh.KVDelete([]byte("k-2")))
+	_, err = fh.KVGet([]byte("k-2"))
+	require.ErrorIs(t, err, akcore.ErrNotFound)
+}
@@ -0,0 +1,140 @@
+package rosco
+
+import (
+	"fmt"
+	"math"
+)
+
+// sampleTrack is the value of a track elapsedMS after it starts. Before the
+// first keyframe it's the first keyframe's value and after the last it's the
+// last's.
+func sampleTrack(track *KeyframeTrack, elapsedMS int64) float32 {
+	keyframes := track.GetKeyframes()
+	for i := len(keyframes) - 1; i >= 0; i-- {
+		from := keyframes[i]
+		if int64(from.GetTimeMs()) > elapsedMS {
+			continue
+		}
+		if i == len(keyframes)-1 {
+			return from.GetValue()
+		}
+		to := keyframes[i+1]
+		progress := float64(elapsedMS-int64(from.GetTimeMs())) / float64(to.GetTimeMs()-from.GetTimeMs())
+		progress = ease(from.GetCurve(), progress)
+		return from.GetValue() + float32(progress*float64(to.GetValue()-from.GetValue()))
+	}
+	return keyframes[0].GetValue()
+}
+
+// trackEndMS is the time of a track's last keyframe
+func trackEndMS(track *KeyframeTrack) int64 {
+	keyframes := track.GetKeyframes()
+	if len(keyframes) == 0 {
+		return 0
+	}
+	return int64(keyframes[len(keyframes)-1].GetTimeMs())
+}
+
+// tracksDurationMS is how long it takes to play every track
+func tracksDurationMS(tracks []*KeyframeTrack) uint32 {
+	var durationMS int64
+	for _, track := range tracks {
+		durationMS = max(durationMS, trackEndMS(track))
+	}
+	return uint32(durationMS)
+}
+
+// trackState is how far a running track has got
+type trackState struct {
+	frames *frameSender
+	// the track's next frame is due at due
+	due  int64
+	done bool
+}
+
+// doTracks sends a frame for each track that's due, from its first keyframe
+// until its last, returning true when every track is done. Frames are
+// scheduled from when the runner started and each track's last frame is at
+// its last keyframe's time.
+func (sr *scriptRunner) doTracks(now int64) bool {
+	if sr.trackStates == nil {
+		sr.trackStates = make([]*trackState, len(sr.tracks))
+		for i, track := range sr.tracks {
+			state := &trackState{
+				frames: sr.newFrameSender(sr.trackTarget(track), track.GetFps()),
+				done:   len(track.GetKeyframes()) == 0,
+			}
+			if !state.done {
+				state.due = sr.startedAt + int64(track.GetKeyframes()[0].GetTimeMs())
+			}
+			sr.trackStates[i] = state
+		}
+	}
+	elapsed := now - sr.startedAt
+	done := true
+	sr.due = math.MaxInt64
+	for i, track := range sr.tracks {
+		state := sr.trackStates[i]
+		if state.done {
+			continue
+		}
+		if state.due <= now {
+			end := trackEndMS(track)
+			value := float64(sampleTrack(track, elapsed))
+			send := state.frames.frame
+			if elapsed >= end {
+				send = state.frames.final
+				state.done = true
+			}
+			if send(value) {
+				sr.send(sr.trackTarget(track), track.GetAddress(), []*OSCValue{
+					{Value: &OSCValue_Float32{Float32: float32(value)}},
+				})
+			}
+			state.due = min(nextFrame(state.due, now, state.frames.intervalMS), sr.startedAt+end)
+		}
+		if !state.done {
+			done = false
+			sr.due = min(sr.due, state.due)
+		}
+	}
+	return done
+}
+
+// trackTarget is the target a track sends to, its own or the script's
+func (sr *scriptRunner) trackTarget(track *KeyframeTrack) string {
+	if track.GetTarget() != "" {
+		return track.GetTarget()
+	}
+	return sr.target
+}
+
+// validateTrack checks a single keyframe track, returning a description of
+// each problem found
+func validateTrack(track *KeyframeTrack) []string {
+	var problems []string
+	if err := validateOSCAddress(track.GetAddress()); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if track.GetTarget() != "" && track.GetRole() != "" {
+		problems = append(problems, "track has both a target and a role")
+	}
+	if track.GetFps() != 0 {
+		if err := validateFPS(track.GetFps()); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	keyframes := track.GetKeyframes()
+	if len(keyframes) == 0 {
+		problems = append(problems, "track has no keyframes")
+	}
+	for i, keyframe := range keyframes {
+		if _, ok := fadeCurveNames[keyframe.GetCurve()]; !ok {
+			problems = append(problems, fmt.Sprintf("keyframe %d has unknown curve %d", i, keyframe.GetCurve()))
+		}
+		if i > 0 && keyframe.GetTimeMs() < keyframes[i-1].GetTimeMs() {
+			problems = append(problems, fmt.Sprintf("keyframe %d is before keyframe %d", i, i-1))
+		}
+	}
+	return problems
+}
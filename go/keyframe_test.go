@@ -0,0 +1,112 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleTrack(t *testing.T) {
+	t.Parallel()
+	track := &KeyframeTrack{Keyframes: []*Keyframe{
+		{TimeMs: 1000, Value: 0},
+		{TimeMs: 2000, Value: 1, Curve: FadeCurve_CurveEaseIn},
+		{TimeMs: 3000, Value: 0},
+		{TimeMs: 3000, Value: 0.5},
+	}}
+	for _, tc := range []struct {
+		elapsedMS int64
+		value     float32
+	}{
+		{0, 0},
+		{1500, 0.5},
+		{2500, 0.75},
+		{2999, 0.002},
+		{3000, 0.5},
+		{5000, 0.5},
+	} {
+		require.InDelta(t, tc.value, sampleTrack(track, tc.elapsedMS), 0.00001, "at %d", tc.elapsedMS)
+	}
+}
+
+func TestKeyframeTracks(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	script := &Script{Tracks: []*KeyframeTrack{
+		{Address: "/fader", Keyframes: []*Keyframe{{TimeMs: 0, Value: 0}, {TimeMs: 100, Value: 1}}},
+		{Address: "/dimmer", Role: "lights", Keyframes: []*Keyframe{{TimeMs: 50, Value: 1}}},
+	}}
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_SIMULATE_REQ), &ScriptSimulateRequest{
+		Target: "mixer",
+		Script: script,
+		Roles:  map[string]string{"lights": "lx"},
+	})
+	require.Nil(t, reply.Error)
+	ssr := &ScriptSimulateResponse{}
+	require.NoError(t, ssr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, uint32(100), ssr.GetDurationMs(), "the last frame is at the last keyframe")
+	var dimmer []*SimulatedMessage
+	var fader int
+	for _, msg := range ssr.GetMessages() {
+		if msg.GetAddress() == "/dimmer" {
+			dimmer = append(dimmer, msg)
+		} else {
+			fader++
+		}
+	}
+	require.Equal(t, 8, fader, "every frame until the last keyframe")
+	require.Equal(t, []*SimulatedMessage{
+		{OffsetMs: 50, Target: "lx", Address: "/dimmer", Values: float32Values(1)},
+	}, dimmer, "a single keyframe is sent once")
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "mixer",
+		Script: script,
+		Roles:  map[string]string{"lights": "lx"},
+	})
+	require.Nil(t, reply.Error)
+	tick(t, rsc, fh, 1000)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/fader", values: float32Values(0)}}, fh.takeSent())
+	tick(t, rsc, fh, 1200)
+	require.Len(t, fh.takeSent(), 2)
+	require.Empty(t, rsc.runners)
+
+	script.Actions = []*ScriptAction{{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1}}
+	script.Tracks[0].Keyframes[1].TimeMs = 0
+	script.Tracks[0].Keyframes[0].TimeMs = 10
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Revision: rsc.cfg.GetRevision(),
+			Scripts:  map[int32]*Script{1: script},
+		},
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `script 1: script has both actions and tracks; `+
+		`script 1: track 0: keyframe 1 is before keyframe 0`,
+		reply.Error.GetDetail(),
+	)
+}
+
+func TestScriptTextTracks(t *testing.T) {
+	t.Parallel()
+	text := "track /intro/fader on \"lights\"\n" +
+		"key 0ms 0.0 ease-in\n" +
+		"key 30s 1.0\n" +
+		"track /intro/pan on role \"audio\"\n" +
+		"key 1500ms -1.0\n"
+	script, textErrs := parseScriptText(text)
+	require.Empty(t, textErrs)
+	require.Len(t, script.GetTracks(), 2)
+	require.Equal(t, uint32(30_000), script.GetTracks()[0].GetKeyframes()[1].GetTimeMs())
+	rendered, err := renderScriptText(script)
+	require.NoError(t, err)
+	require.Equal(t, text, rendered)
+
+	_, textErrs = parseScriptText("key 0s 1.0\nsleep 1s\ntrack /a\nkey 1s x\n")
+	require.Equal(t, []*ScriptTextError{
+		{Line: 1, Column: 1, Message: "key must follow a track"},
+		{Line: 3, Column: 1, Message: "script can't have both actions and tracks"},
+		{Line: 4, Column: 8, Message: `invalid key value "x"`},
+	}, textErrs)
+}
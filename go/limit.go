@@ -0,0 +1,156 @@
+package rosco
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// a limitedSend is the values last sent to an address under a rate limit, and
+// when they were sent
+type limitedSend struct {
+	values []*OSCValue
+	at     int64
+}
+
+// addressMatches reports whether pattern selects address. A pattern ending in
+// / selects every address under it.
+func addressMatches(pattern, address string) bool {
+	return pattern == address ||
+		strings.HasSuffix(pattern, "/") && strings.HasPrefix(address, pattern)
+}
+
+func oscValueType(v *OSCValue) OSCValueType {
+	switch v.GetValue().(type) {
+	case *OSCValue_Int32:
+		return OSCValueType_OSCTypeInt32
+	case *OSCValue_Float32:
+		return OSCValueType_OSCTypeFloat32
+	case *OSCValue_String_:
+		return OSCValueType_OSCTypeString
+	case *OSCValue_Blob:
+		return OSCValueType_OSCTypeBlob
+	case *OSCValue_Int64:
+		return OSCValueType_OSCTypeInt64
+	case *OSCValue_True, *OSCValue_False:
+		return OSCValueType_OSCTypeBool
+	}
+	return OSCValueType_OSCTypeNil
+}
+
+// oscNumber is the value of an int32, int64, or float32 OSC value
+func oscNumber(v *OSCValue) (float64, bool) {
+	switch v := v.GetValue().(type) {
+	case *OSCValue_Int32:
+		return float64(v.Int32), true
+	case *OSCValue_Int64:
+		return float64(v.Int64), true
+	case *OSCValue_Float32:
+		return float64(v.Float32), true
+	}
+	return 0, false
+}
+
+// clampNumber returns v clamped to lo-hi and whether that changed it. Values
+// that aren't numbers are returned unchanged.
+func clampNumber(v *OSCValue, lo, hi float64) (*OSCValue, bool) {
+	n, ok := oscNumber(v)
+	if !ok || n >= lo && n <= hi {
+		return v, false
+	}
+	switch v.GetValue().(type) {
+	case *OSCValue_Int32:
+		n = min(max(n, math.Ceil(lo)), math.Floor(hi))
+		return &OSCValue{Value: &OSCValue_Int32{Int32: int32(n)}}, true
+	case *OSCValue_Int64:
+		n = min(max(n, math.Ceil(lo)), math.Floor(hi))
+		return &OSCValue{Value: &OSCValue_Int64{Int64: int64(n)}}, true
+	}
+	return &OSCValue{Value: &OSCValue_Float32{Float32: float32(min(max(n, lo), hi))}}, true
+}
+
+// applyLimits checks values against the limits for an address on target,
+// returning the values to send. Values are clamped or an error is returned,
+// depending on each limit's mode. Rate limits are only checked if checkRate is
+// set, but the values sent are always recorded for them.
+func (rsc *Rosco) applyLimits(target, address string, values []*OSCValue, checkRate bool) ([]*OSCValue, error) {
+	dest := oscDestination{target: target, address: address}
+	var clamped, rated bool
+	var now int64
+	// clamp copies values before changing them, so the caller's are untouched
+	clamp := func(i int, limit *AddressLimit, lo, hi float64) error {
+		v, changed := clampNumber(values[i], lo, hi)
+		if !changed {
+			return nil
+		}
+		if limit.GetMode() == LimitMode_LimitReject {
+			return fmt.Errorf("value %d is outside the limit for %s", i, limit.GetAddress())
+		}
+		if !clamped {
+			values = slices.Clone(values)
+			clamped = true
+		}
+		values[i] = v
+		return nil
+	}
+
+	for _, limit := range rsc.cfg.GetLimits()[target].GetAddresses() {
+		if !addressMatches(limit.GetAddress(), address) {
+			continue
+		}
+		if limit.GetDeny() {
+			return nil, errors.New("address is denied")
+		}
+		if allowed := limit.GetAllowedTypes(); len(allowed) > 0 {
+			for i, v := range values {
+				if vt := oscValueType(v); !slices.Contains(allowed, vt) {
+					return nil, fmt.Errorf("value %d has type %s, which isn't allowed", i, vt)
+				}
+			}
+		}
+		if r := limit.GetRange(); r != nil {
+			for i := range values {
+				if err := clamp(i, limit, float64(r.GetMin()), float64(r.GetMax())); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if limit.GetMaxRate() <= 0 {
+			continue
+		}
+		if !rated {
+			var err error
+			if now, err = rsc.host.CurrentTimeMillis(); err != nil {
+				return nil, fmt.Errorf("getting time: %w", err)
+			}
+			rated = true
+		}
+		if !checkRate {
+			continue
+		}
+		last, present := rsc.limitedSent[dest]
+		if !present || len(last.values) != len(values) {
+			continue
+		}
+		maxChange := float64(limit.GetMaxRate()) * float64(now-last.at) / 1000
+		for i := range values {
+			prev, ok := oscNumber(last.values[i])
+			if !ok {
+				continue
+			}
+			if err := clamp(i, limit, prev-maxChange, prev+maxChange); err != nil {
+				return nil, fmt.Errorf("changing too fast: %w", err)
+			}
+		}
+	}
+
+	if clamped {
+		rsc.host.LogInfo("clamped OSC message", "target", target, "address", address)
+	}
+	if rated {
+		rsc.limitedSent[dest] = limitedSend{values: values, at: now}
+	}
+	return values, nil
+}
@@ -0,0 +1,100 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressMatches(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		pattern, address string
+		match            bool
+	}{
+		{"/ch/01/fader", "/ch/01/fader", true},
+		{"/ch/01/fader", "/ch/01/fader/x", false},
+		{"/ch/", "/ch/01/fader", true},
+		{"/ch/", "/ch", false},
+		{"/ch", "/ch/01", false},
+		{"/", "/main", true},
+	} {
+		require.Equal(t, tc.match, addressMatches(tc.pattern, tc.address), "%s %s", tc.pattern, tc.address)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Limits: map[string]*TargetLimits{
+				"mixer": {Addresses: []*AddressLimit{
+					{Address: "/ch/", Range: &LimitRange{Min: 0, Max: 0.75}},
+					{Address: "/ch/01/fader", MaxRate: 0.5},
+					{Address: "/main/", Range: &LimitRange{Min: 0, Max: 0.75}, Mode: LimitMode_LimitReject},
+					{Address: "/main/name", AllowedTypes: []OSCValueType{OSCValueType_OSCTypeString}},
+					{Address: "/config/", Deny: true},
+				}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+	intValues := func(v int32) []*OSCValue {
+		return []*OSCValue{{Value: &OSCValue_Int32{Int32: v}}}
+	}
+
+	fh.now = 1000
+	rsc.sendOSC("mixer", "/ch/02/fader", float32Values(1))
+	rsc.sendOSC("mixer", "/ch/02/on", intValues(2))
+	rsc.sendOSC("lights", "/ch/02/fader", float32Values(1))
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/ch/02/fader", values: float32Values(0.75)},
+		{target: "mixer", address: "/ch/02/on", values: intValues(0)},
+		{target: "lights", address: "/ch/02/fader", values: float32Values(1)},
+	}, fh.takeSent())
+	require.Equal(t, float32Values(0.75), rsc.lastSent[oscDestination{target: "mixer", address: "/ch/02/fader"}],
+		"the value actually sent is kept for scenes",
+	)
+
+	// the first value to a rate limited address is sent as is
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0))
+	fh.now = 1500
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0.5))
+	fh.now = 2000
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0.3))
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/ch/01/fader", values: float32Values(0)},
+		{target: "mixer", address: "/ch/01/fader", values: float32Values(0.25)},
+		{target: "mixer", address: "/ch/01/fader", values: float32Values(0.3)},
+	}, fh.takeSent())
+
+	fh.logs = nil
+	rsc.sendOSC("mixer", "/main/fader", float32Values(0.5))
+	rsc.sendOSC("mixer", "/main/fader", float32Values(1))
+	rsc.sendOSC("mixer", "/main/name", intValues(1))
+	rsc.sendOSC("mixer", "/main/name", []*OSCValue{{Value: &OSCValue_String_{String_: "Main"}}})
+	rsc.sendOSC("mixer", "/config/reset", nil)
+	require.Len(t, fh.takeSent(), 2)
+	var rejected int
+	for _, entry := range fh.logs {
+		if entry.level == "error" && entry.message == "rejected OSC message" {
+			rejected++
+		}
+	}
+	require.Equal(t, 3, rejected)
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Revision: rsc.cfg.GetRevision(),
+			Limits: map[string]*TargetLimits{
+				"mixer": {Addresses: []*AddressLimit{
+					{Address: "ch", Range: &LimitRange{Min: 1, Max: 0}, MaxRate: -1},
+				}},
+			},
+		},
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Contains(t, reply.Error.GetDetail(), `limits for "mixer" address 0: range minimum 1 is above maximum 0`)
+}
@@ -0,0 +1,104 @@
+package rosco
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/autonomouskoi/akcore"
+)
+
+var (
+	masterLevelsKVKey = []byte("master-levels")
+)
+
+// loadMasterLevels retrieves the master levels
+func (rsc *Rosco) loadMasterLevels() error {
+	rsc.masterLevels = &MasterLevels{}
+	err := rsc.kvGetProto(masterLevelsKVKey, rsc.masterLevels)
+	if err != nil && !errors.Is(err, akcore.ErrNotFound) {
+		return err
+	}
+	if rsc.masterLevels.Levels == nil {
+		rsc.masterLevels.Levels = map[string]float32{}
+	}
+	return nil
+}
+
+// masterLevel is the level of the named master
+func (rsc *Rosco) masterLevel(name string) float32 {
+	level, present := rsc.masterLevels.GetLevels()[name]
+	if !present {
+		return 1
+	}
+	return level
+}
+
+// masterMatches reports whether master scales an address on target
+func masterMatches(master *Master, target, address string) bool {
+	for _, ma := range master.GetAddresses() {
+		if ma.GetTarget() == target && addressMatches(ma.GetAddress(), address) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressLevel is the level values sent to an address are scaled by, the
+// product of the levels of the masters for that address
+func (rsc *Rosco) addressLevel(target, address string) float32 {
+	level := float32(1)
+	for name, master := range rsc.cfg.GetMasters() {
+		if masterMatches(master, target, address) {
+			level *= rsc.masterLevel(name)
+		}
+	}
+	return level
+}
+
+// scaleValues scales the float32 values in values by level
+func scaleValues(values []*OSCValue, level float32) []*OSCValue {
+	if level == 1 {
+		return values
+	}
+	scaled := make([]*OSCValue, len(values))
+	for i, v := range values {
+		if f, ok := v.GetValue().(*OSCValue_Float32); ok {
+			v = &OSCValue{Value: &OSCValue_Float32{Float32: f.Float32 * level}}
+		}
+		scaled[i] = v
+	}
+	return scaled
+}
+
+// setMasterLevel sets the level of the named master, clamped to 0.0-1.0, and
+// sends the values held at its addresses again at the new level
+func (rsc *Rosco) setMasterLevel(name string, level float32) (float32, error) {
+	master, present := rsc.cfg.GetMasters()[name]
+	if !present {
+		return 0, fmt.Errorf("no master %q", name)
+	}
+	level = min(max(level, 0), 1)
+	rsc.masterLevels.Levels[name] = level
+	if err := rsc.kvSetProto(masterLevelsKVKey, rsc.masterLevels); err != nil {
+		rsc.host.LogError("writing master levels", "error", err.Error())
+	}
+
+	var held []oscDestination
+	for dest := range rsc.lastSent {
+		if masterMatches(master, dest.target, dest.address) {
+			held = append(held, dest)
+		}
+	}
+	slices.SortFunc(held, func(a, b oscDestination) int {
+		if c := strings.Compare(a.target, b.target); c != 0 {
+			return c
+		}
+		return strings.Compare(a.address, b.address)
+	})
+	for _, dest := range held {
+		rsc.sendTargetOSC(dest.target, dest.address, rsc.lastSent[dest], false)
+	}
+	return level, nil
+}
@@ -0,0 +1,109 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMasters(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Masters: map[string]*Master{
+				"grand": {Addresses: []*MasterAddress{{Target: "mixer", Address: "/ch/"}}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+
+	setMaster := func(level float32) float32 {
+		t.Helper()
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_MASTER_SET_REQ), &MasterSetRequest{
+			Master: "grand",
+			Level:  level,
+		})
+		require.Nil(t, reply.Error)
+		msr := &MasterSetResponse{}
+		require.NoError(t, msr.UnmarshalVT(reply.GetMessage()))
+		return msr.GetLevel()
+	}
+
+	intValue := []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0.8))
+	rsc.sendOSC("mixer", "/ch/01/on", intValue)
+	rsc.sendOSC("mixer", "/main/fader", float32Values(0.8))
+	rsc.sendOSC("lights", "/ch/01/fader", float32Values(0.8))
+	fh.takeSent()
+
+	require.Equal(t, float32(0.5), setMaster(0.5))
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/ch/01/fader", values: float32Values(0.4)},
+		{target: "mixer", address: "/ch/01/on", values: intValue},
+	}, fh.takeSent(), "held values are sent again at the new level")
+
+	rsc.sendOSC("mixer", "/ch/02/fader", float32Values(1))
+	require.Equal(t, float32Values(0.5), fh.takeSent()[0].values)
+
+	require.Equal(t, float32(1), setMaster(2), "levels are clamped")
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/ch/01/fader", values: float32Values(0.8)},
+		{target: "mixer", address: "/ch/01/on", values: intValue},
+		{target: "mixer", address: "/ch/02/fader", values: float32Values(1)},
+	}, fh.takeSent(), "held values aren't scaled twice")
+
+	rsc.runScript("mixer", []*ScriptAction{{
+		Type:   ScriptActionType_ActionTypeMaster,
+		Master: "grand",
+		Values: float32Values(0.25),
+	}})
+	tick(t, rsc, fh, 1000)
+	require.Len(t, fh.takeSent(), 3)
+
+	// levels are kept across restarts
+	rsc, err := newRosco(fh)
+	require.NoError(t, err)
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_MASTER_LEVELS_REQ), &MasterLevelsRequest{})
+	require.Nil(t, reply.Error)
+	mlr := &MasterLevelsResponse{}
+	require.NoError(t, mlr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, map[string]float32{"grand": 0.25}, mlr.GetLevels())
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_MASTER_SET_REQ), &MasterSetRequest{
+		Master: "missing",
+	})
+	require.Equal(t, int32(core.CommonErrorCode_NOT_FOUND), reply.Error.GetCode())
+}
+
+func TestMastersRecordLimitedValues(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Masters: map[string]*Master{
+				"grand": {Addresses: []*MasterAddress{{Target: "mixer", Address: "/fader"}}},
+			},
+			Limits: map[string]*TargetLimits{
+				"mixer": {Addresses: []*AddressLimit{{Address: "/fader", Range: &LimitRange{Min: 0, Max: 0.25}}}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+	_, err := rsc.setMasterLevel("grand", 0.5)
+	require.NoError(t, err)
+
+	rsc.sendOSC("mixer", "/fader", float32Values(1))
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/fader", values: float32Values(0.25)}}, fh.takeSent())
+	require.Equal(t, float32Values(0.5), rsc.lastSent[oscDestination{target: "mixer", address: "/fader"}],
+		"the value sent is kept at full level",
+	)
+
+	_, err = rsc.setMasterLevel("grand", 0)
+	require.NoError(t, err)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/fader", values: float32Values(0)}}, fh.takeSent())
+	require.Equal(t, float32Values(0.5), rsc.lastSent[oscDestination{target: "mixer", address: "/fader"}],
+		"a value sent at level 0 doesn't replace what's kept",
+	)
+}
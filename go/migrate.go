@@ -0,0 +1,47 @@
+package rosco
+
+import (
+	"fmt"
+)
+
+// A migration upgrades a config from one schema version to the next
+type migration func(cfg *Config) error
+
+// migrations are applied in order. migrations[i] upgrades a config with
+// schema version i to version i+1.
+var migrations = []migration{
+	migrateV0,
+}
+
+// currentSchemaVersion is the schema version of configs written by this
+// version of Rosco
+var currentSchemaVersion = uint32(len(migrations))
+
+// configs written before schema versioning need no changes
+func migrateV0(cfg *Config) error {
+	return nil
+}
+
+// backupKVKey is where a config with the given schema version is kept before
+// it's migrated
+func backupKVKey(version uint32) []byte {
+	return []byte(fmt.Sprintf("config-backup-v%d", version))
+}
+
+// migrateConfig applies all migrations needed to bring cfg up to the current
+// schema version.
+func migrateConfig(cfg *Config) error {
+	version := cfg.GetSchemaVersion()
+	if version > currentSchemaVersion {
+		return fmt.Errorf("config schema version %d is newer than supported version %d",
+			version, currentSchemaVersion,
+		)
+	}
+	for ; version < currentSchemaVersion; version++ {
+		if err := migrations[version](cfg); err != nil {
+			return fmt.Errorf("migrating from schema version %d: %w", version, err)
+		}
+		cfg.SchemaVersion = version + 1
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateConfig(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name    string
+		version uint32
+		err     string
+	}{
+		{name: "unversioned", version: 0},
+		{name: "current", version: currentSchemaVersion},
+		{
+			name:    "newer",
+			version: currentSchemaVersion + 1,
+			err:     "config schema version 2 is newer than supported version 1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &Config{
+				SchemaVersion: tc.version,
+				Scripts:       map[int32]*Script{1: {Name: "show"}},
+			}
+			err := migrateConfig(cfg)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				require.Equal(t, tc.version, cfg.GetSchemaVersion(), "a failed migration leaves the version alone")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, currentSchemaVersion, cfg.GetSchemaVersion())
+			require.Equal(t, "show", cfg.GetScripts()[1].GetName())
+		})
+	}
+}
+
+func TestLoadConfigMigrates(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name     string
+		version  uint32
+		migrated bool
+		err      string
+	}{
+		{name: "unversioned", version: 0, migrated: true},
+		{name: "current", version: currentSchemaVersion},
+		{
+			name:    "newer",
+			version: currentSchemaVersion + 1,
+			err: "loading config: migrating config: " +
+				"config schema version 2 is newer than supported version 1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			fh := newFakeHost()
+			stored, err := (&Config{
+				Revision:      3,
+				SchemaVersion: tc.version,
+				Scripts:       map[int32]*Script{1: {Name: "show"}},
+			}).MarshalVT()
+			require.NoError(t, err)
+			fh.kv[string(cfgKVKey)] = stored
+
+			rsc, err := newRosco(fh)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				require.Equal(t, stored, fh.kv[string(cfgKVKey)], "the stored config isn't touched")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, currentSchemaVersion, rsc.cfg.GetSchemaVersion())
+			require.Equal(t, "show", rsc.cfg.GetScripts()[1].GetName())
+			if !tc.migrated {
+				require.Equal(t, uint64(3), rsc.cfg.GetRevision())
+				require.NotContains(t, fh.kv, string(backupKVKey(tc.version)))
+				return
+			}
+			require.Equal(t, stored, fh.kv[string(backupKVKey(tc.version))], "the original is backed up")
+			require.Equal(t, uint64(4), rsc.cfg.GetRevision(), "the migrated config is a new revision")
+			cr, err := rsc.loadRevision(4)
+			require.NoError(t, err)
+			require.Equal(t, "migrated from schema version 0", cr.GetComment())
+		})
+	}
+}
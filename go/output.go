@@ -0,0 +1,84 @@
+package rosco
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	defaultFPS = 60
+	minFPS     = 1
+	maxFPS     = 240
+)
+
+// validateFPS checks a frame rate that's been set
+func validateFPS(fps float32) error {
+	if fps < minFPS || fps > maxFPS {
+		return fmt.Errorf("fps %v is outside %d-%d", fps, minFPS, maxFPS)
+	}
+	return nil
+}
+
+// frameIntervalMS is how long to wait between frames at fps
+func frameIntervalMS(fps float32) int64 {
+	if fps <= 0 {
+		fps = defaultFPS
+	}
+	return max(int64(1000/fps), 1)
+}
+
+// a frameSender decides which frames of a fade or track are sent
+type frameSender struct {
+	intervalMS int64
+	resolution float64
+	sent       bool
+	// the last value sent, and that value rounded to resolution
+	last, lastRounded float64
+}
+
+// newFrameSender makes the frame sender for a fade or track sending to target.
+// The frame rate is the first that's set of fps, the script's, and the
+// target's.
+func (sr *scriptRunner) newFrameSender(target string, fps float32) *frameSender {
+	output := sr.outputs[target]
+	if fps <= 0 {
+		fps = sr.fps
+	}
+	if fps <= 0 {
+		fps = output.GetFps()
+	}
+	return &frameSender{
+		intervalMS: frameIntervalMS(fps),
+		resolution: float64(output.GetResolution()),
+	}
+}
+
+// round value to the nearest multiple of the resolution
+func (fs *frameSender) round(value float64) float64 {
+	if fs.resolution <= 0 {
+		return value
+	}
+	return math.Round(value/fs.resolution) * fs.resolution
+}
+
+// frame reports whether a frame with value should be sent, recording it as
+// sent if so
+func (fs *frameSender) frame(value float64) bool {
+	rounded := fs.round(value)
+	if fs.sent && rounded == fs.lastRounded {
+		return false
+	}
+	fs.sent, fs.last, fs.lastRounded = true, value, rounded
+	return true
+}
+
+// final reports whether the last frame, with value, should be sent. It's only
+// skipped when the last value sent was exactly the same, so fades and tracks
+// always end on their final value.
+func (fs *frameSender) final(value float64) bool {
+	if fs.sent && value == fs.last {
+		return false
+	}
+	fs.sent, fs.last, fs.lastRounded = true, value, fs.round(value)
+	return true
+}
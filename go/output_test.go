@@ -0,0 +1,120 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameSender(t *testing.T) {
+	t.Parallel()
+	fs := &frameSender{resolution: 0.25}
+	var sent []float64
+	for _, v := range []float64{0, 0.1, 0.2, 0.3, 0.4, 0.6, 0.9} {
+		if fs.frame(v) {
+			sent = append(sent, v)
+		}
+	}
+	require.Equal(t, []float64{0, 0.2, 0.4, 0.9}, sent)
+	require.True(t, fs.final(1), "the final value is sent even when it rounds the same")
+	require.False(t, fs.final(1), "but not when it's exactly the same")
+
+	fs = &frameSender{}
+	require.True(t, fs.frame(0.5))
+	require.False(t, fs.frame(0.5))
+	require.True(t, fs.frame(0.50001))
+}
+
+func TestOutputFrameRates(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Outputs: map[string]*TargetOutput{"slow": {Fps: 10, Resolution: 0.25}},
+		},
+	})
+	require.Nil(t, reply.Error)
+
+	simulate := func(script *Script) []*SimulatedMessage {
+		t.Helper()
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_SIMULATE_REQ), &ScriptSimulateRequest{
+			Target: "slow",
+			Script: script,
+		})
+		require.Nil(t, reply.Error)
+		ssr := &ScriptSimulateResponse{}
+		require.NoError(t, ssr.UnmarshalVT(reply.GetMessage()))
+		return ssr.GetMessages()
+	}
+	fade := &ScriptAction{
+		Type:       ScriptActionType_ActionTypeFade,
+		Address:    "/fader",
+		Values:     float32Values(0, 1),
+		DurationMs: 1000,
+	}
+
+	msgs := simulate(&Script{Actions: []*ScriptAction{fade}})
+	var offsets []uint32
+	for _, msg := range msgs {
+		offsets = append(offsets, msg.GetOffsetMs())
+	}
+	require.Equal(t, []uint32{0, 200, 400, 700, 900, 1000}, offsets,
+		"frames are 100ms apart and those rounding to the same value are skipped",
+	)
+	require.Equal(t, float32Values(1), msgs[len(msgs)-1].GetValues())
+
+	msgs = simulate(&Script{Fps: 20, Actions: []*ScriptAction{fade}})
+	require.Equal(t, uint32(150), msgs[1].GetOffsetMs(),
+		"the script's fps overrides the target's, frames 50ms apart still rounding to 0 are skipped",
+	)
+
+	fade.Fps = 5
+	msgs = simulate(&Script{Fps: 20, Actions: []*ScriptAction{fade}})
+	require.Equal(t, uint32(200), msgs[1].GetOffsetMs(), "the action's fps overrides the script's")
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Revision: rsc.cfg.GetRevision(),
+			Scripts: map[int32]*Script{1: {Actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1, Fps: 30},
+			}}},
+			Outputs: map[string]*TargetOutput{"slow": {Fps: 1000, Resolution: -1}},
+		},
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `script 1 action 0: only fade actions have an fps; `+
+		`output for "slow": fps 1000 is outside 1-240; `+
+		`output for "slow": resolution -1 is negative`,
+		reply.Error.GetDetail(),
+	)
+}
+
+func TestScriptTextFrameRates(t *testing.T) {
+	t.Parallel()
+	text := "fps 30\n" +
+		"fade /slow/fader 0.0 -> 1.0 in 60s ease-in 10fps\n" +
+		"fade /fast/fader 0.0 -> 1.0 in 1s 120fps\n"
+	script, textErrs := parseScriptText(text)
+	require.Empty(t, textErrs)
+	require.Equal(t, float32(30), script.GetFps())
+	require.Equal(t, float32(10), script.GetActions()[0].GetFps())
+	rendered, err := renderScriptText(script)
+	require.NoError(t, err)
+	require.Equal(t, text, rendered)
+
+	text = "track /slow/pan 5fps on \"lights\"\n" +
+		"key 0ms 0.0\n"
+	script, textErrs = parseScriptText(text)
+	require.Empty(t, textErrs)
+	require.Equal(t, float32(5), script.GetTracks()[0].GetFps())
+	rendered, err = renderScriptText(script)
+	require.NoError(t, err)
+	require.Equal(t, text, rendered)
+
+	_, textErrs = parseScriptText("fps 0\nfade /a 0.0 -> 1.0 in 1s linear 1000fps\n")
+	require.Equal(t, []*ScriptTextError{
+		{Line: 1, Column: 5, Message: `invalid fps "0"`},
+		{Line: 2, Column: 33, Message: `invalid fps "1000fps"`},
+	}, textErrs)
+}
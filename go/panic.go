@@ -0,0 +1,54 @@
+package rosco
+
+import (
+	"sort"
+
+	"github.com/autonomouskoi/core-tinygo"
+)
+
+// emergencyStop cancels every running script, scheduled run, and waiting
+// cue, then sends each target its safe state. Nothing limits how often it
+// runs; every call stops everything and is published as an event. It returns
+// how many runners, scheduled runs, and waiting cues were cancelled.
+func (rsc *Rosco) emergencyStop(source string) uint32 {
+	cancelled := uint32(len(rsc.runners) + len(rsc.scheduledRuns) + len(rsc.pendingCues))
+	rsc.runners = map[int]*scriptRunner{}
+	rsc.scheduledRuns = map[int32]*scheduledRun{}
+	rsc.runnersChanged = true
+	rsc.pendingCues = map[string]*pendingCue{}
+	rsc.writeCueStates()
+
+	targets := make([]string, 0, len(rsc.cfg.GetSafeStates()))
+	for target := range rsc.cfg.GetSafeStates() {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		for _, action := range rsc.cfg.GetSafeStates()[target].GetActions() {
+			if action.GetType() == ScriptActionType_ActionTypeSet {
+				rsc.sendSafeOSC(target, action.GetAddress(), action.GetValues())
+			}
+		}
+	}
+
+	now, err := rsc.host.CurrentTimeMillis()
+	if err != nil {
+		rsc.host.LogError("getting current time", "error", err.Error())
+	}
+	rsc.host.LogInfo("panic", "source", source, "runners_cancelled", cancelled)
+	msg := &core.BusMessage{
+		Topic: BusTopic_ROSCO_EVENT.String(),
+		Type:  int32(MessageTypeEvent_PANIC_EVENT),
+	}
+	core.MarshalMessage(msg, &PanicEvent{
+		TimestampMs:      now,
+		Source:           source,
+		RunnersCancelled: cancelled,
+	})
+	if msg.Error == nil {
+		if err := rsc.host.Send(msg); err != nil {
+			rsc.host.LogError("sending panic event", "error", err.Error())
+		}
+	}
+	return cancelled
+}
@@ -0,0 +1,109 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanic(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	muteValue := []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Triggers: map[string]*Trigger{
+				"stop": {Panic: true, Group: "show"},
+			},
+			SafeStates: map[string]*SafeState{
+				"mixer": {Actions: []*ScriptAction{
+					{Type: ScriptActionType_ActionTypeSet, Address: "/main/mute", Values: muteValue},
+				}},
+				"lights": {Actions: []*ScriptAction{
+					{Type: ScriptActionType_ActionTypeSet, Address: "/dimmer", Values: float32Values(0.3)},
+				}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+
+	longFade := []*ScriptAction{{
+		Type:       ScriptActionType_ActionTypeFade,
+		Address:    "/ch/01/fader",
+		Values:     float32Values(0, 1),
+		DurationMs: 10_000,
+	}}
+	rsc.runScript("mixer", longFade)
+	rsc.runScript("mixer", longFade)
+	tick(t, rsc, fh, 1000)
+	fh.takeSent()
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_PANIC_REQ), &PanicRequest{})
+	require.Nil(t, reply.Error)
+	pr := &PanicResponse{}
+	require.NoError(t, pr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, uint32(2), pr.GetRunnersCancelled())
+	require.Empty(t, rsc.runners)
+	require.Equal(t, []sentMessage{
+		{target: "lights", address: "/dimmer", values: float32Values(0.3)},
+		{target: "mixer", address: "/main/mute", values: muteValue},
+	}, fh.takeSent())
+	tick(t, rsc, fh, 1100)
+	require.Empty(t, fh.takeSent(), "cancelled scripts don't send anything")
+
+	require.Len(t, fh.published, 1)
+	event := fh.published[0]
+	require.Equal(t, BusTopic_ROSCO_EVENT.String(), event.GetTopic())
+	require.Equal(t, int32(MessageTypeEvent_PANIC_EVENT), event.GetType())
+	pe := &PanicEvent{}
+	require.NoError(t, pe.UnmarshalVT(event.GetMessage()))
+	require.Equal(t, &PanicEvent{TimestampMs: 1000, Source: "request", RunnersCancelled: 2}, pe)
+
+	// a panic trigger fires even though its group isn't armed, as often as
+	// it's called
+	for range 3 {
+		rsc.fireTrigger("stop")
+		require.Len(t, fh.takeSent(), 2)
+	}
+	require.Len(t, fh.published, 4)
+}
+
+func TestPanicIgnoresMastersAndRates(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			SafeStates: map[string]*SafeState{
+				"mixer": {Actions: []*ScriptAction{
+					{Type: ScriptActionType_ActionTypeSet, Address: "/main/fader", Values: float32Values(0.2)},
+				}},
+			},
+			Masters: map[string]*Master{
+				"grand": {Addresses: []*MasterAddress{{Target: "mixer", Address: "/main/fader"}}},
+			},
+			Limits: map[string]*TargetLimits{
+				"mixer": {Addresses: []*AddressLimit{{Address: "/main/fader", MaxRate: 0.1}}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+	fh.now = 1000
+	rsc.sendOSC("mixer", "/main/fader", float32Values(1))
+	_, err := rsc.setMasterLevel("grand", 0)
+	require.NoError(t, err)
+	fh.takeSent()
+
+	fh.now = 1001
+	rsc.emergencyStop("request")
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/main/fader", values: float32Values(0.2)},
+	}, fh.takeSent(), "the safe value isn't scaled by the master or held back by the rate limit")
+
+	fh.now = 1002
+	rsc.sendOSC("mixer", "/main/fader", float32Values(1))
+	sent := fh.takeSent()
+	require.Len(t, sent, 1)
+	require.InDelta(t, 0.2, sent[0].values[0].GetFloat32(), 0.001,
+		"traffic after the panic is rate limited from the safe value",
+	)
+}
@@ -1,54 +1,533 @@
 package rosco
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/autonomouskoi/akcore"
 	"github.com/autonomouskoi/core-tinygo"
 )
 
 func (rsc *Rosco) handleRequests() core.TypeRouter {
 	return core.TypeRouter{
-		int32(MessageTypeRequest_CONFIG_GET_REQ): rsc.handleRequestConfigGet,
-		int32(MessageTypeRequest_SCRIPT_RUN_REQ): rsc.handleRequestRunScript,
+		int32(MessageTypeRequest_CONFIG_GET_REQ):           rsc.handleRequestConfigGet,
+		int32(MessageTypeRequest_SCRIPT_RUN_REQ):           rsc.handleRequestRunScript,
+		int32(MessageTypeRequest_CONFIG_HISTORY_LIST_REQ):  rsc.handleRequestConfigHistoryList,
+		int32(MessageTypeRequest_CONFIG_HISTORY_DIFF_REQ):  rsc.handleRequestConfigHistoryDiff,
+		int32(MessageTypeRequest_EXPORT_REQ):               rsc.handleRequestExport,
+		int32(MessageTypeRequest_SCRIPT_COMPILE_REQ):       rsc.handleRequestScriptCompile,
+		int32(MessageTypeRequest_SCRIPT_RENDER_REQ):        rsc.handleRequestScriptRender,
+		int32(MessageTypeRequest_SCRIPT_SIMULATE_REQ):      rsc.handleRequestScriptSimulate,
+		int32(MessageTypeRequest_SCENE_RECALL_REQ):         rsc.handleRequestSceneRecall,
+		int32(MessageTypeRequest_CUE_GO_REQ):               rsc.handleRequestCueGo,
+		int32(MessageTypeRequest_CUE_BACK_REQ):             rsc.handleRequestCueBack,
+		int32(MessageTypeRequest_CUE_JUMP_REQ):             rsc.handleRequestCueJump,
+		int32(MessageTypeRequest_CUE_STATUS_REQ):           rsc.handleRequestCueStatus,
+		int32(MessageTypeRequest_MASTER_SET_REQ):           rsc.handleRequestMasterSet,
+		int32(MessageTypeRequest_MASTER_LEVELS_REQ):        rsc.handleRequestMasterLevels,
+		int32(MessageTypeRequest_PANIC_REQ):                rsc.handleRequestPanic,
+		int32(MessageTypeRequest_TEMPO_SET_REQ):            rsc.handleRequestTempoSet,
+		int32(MessageTypeRequest_TEMPO_GET_REQ):            rsc.handleRequestTempoGet,
+		int32(MessageTypeRequest_TEMPO_TAP_REQ):            rsc.handleRequestTempoTap,
+		int32(MessageTypeRequest_RUNNER_LIST_REQ):          rsc.handleRequestRunnerList,
+		int32(MessageTypeRequest_SCHEDULED_RUN_LIST_REQ):   rsc.handleRequestScheduledRunList,
+		int32(MessageTypeRequest_SCHEDULED_RUN_CANCEL_REQ): rsc.handleRequestScheduledRunCancel,
 	}
 }
 
 func (rsc *Rosco) handleRequestConfigGet(msg *core.BusMessage) *core.BusMessage {
 	reply := core.DefaultReply(msg)
 	core.MarshalMessage(reply, &ConfigGetResponse{
-		Config: rsc.cfg,
+		Config:    rsc.cfg,
+		Timelines: rsc.scriptTimelines(rsc.cfg),
 	})
 	return reply
 }
 
+// requestedScript returns script if it has any actions or tracks, or the
+// configured script with the given ID. A script given in the request is
+// validated like a stored one.
+func (rsc *Rosco) requestedScript(script *Script, scriptID int32) (*Script, *core.Error) {
+	empty := func(script *Script) bool {
+		return len(script.GetActions()) == 0 && len(script.GetTracks()) == 0
+	}
+	if empty(script) {
+		script = rsc.cfg.GetScripts()[scriptID]
+		if script == nil {
+			rsc.host.LogError("no script", "id", scriptID)
+			return nil, core.NotFoundError()
+		}
+	} else if cfgErrs := validateScript(0, script); len(cfgErrs) > 0 {
+		return nil, configErrorsError(cfgErrs)
+	}
+	if empty(script) {
+		return nil, &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String("script has no actions or tracks"),
+		}
+	}
+	return script, nil
+}
+
 func (rsc *Rosco) handleRequestRunScript(msg *core.BusMessage) *core.BusMessage {
 	reply := core.DefaultReply(msg)
 	rsr := &ScriptRunRequest{}
 	reply.Error = core.UnmarshalMessage(msg, rsr)
 	if reply.Error != nil {
-		core.LogBusError("unmarshalling", reply.Error)
+		rsc.host.LogBusError("unmarshalling", reply.Error)
 		return reply
 	}
 
 	// Do the thing
-	actions := rsr.GetScript().GetActions()
-	if len(actions) == 0 {
-		script := rsc.cfg.GetScripts()[rsr.GetScriptId()]
+	script, busErr := rsc.requestedScript(rsr.GetScript(), rsr.GetScriptId())
+	if busErr != nil {
+		reply.Error = busErr
+		return reply
+	}
+	script, err := resolveRoles(script, rsr.GetRoles())
+	if err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+
+	err = rsc.admitRun("request", rsr.GetTarget(), script, func() error {
+		return checkScriptSize(script, rsc.cfg.GetRunnerLimits())
+	})
+	if err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+	if rsr.GetStartAtMs() != 0 && rsr.GetDelayMs() != 0 {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String("start_at_ms and delay_ms are both set"),
+		}
+		return reply
+	}
+	now, err := rsc.host.CurrentTimeMillis()
+	if err != nil {
+		rsc.host.LogError("getting current time", "error", err.Error())
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	at := rsr.GetStartAtMs()
+	if rsr.GetDelayMs() != 0 {
+		at = now + int64(rsr.GetDelayMs())
+	}
+	check := func() error { return rsc.checkRunnerCount(rsr.GetTarget(), script) }
+	if at > now {
+		check = rsc.checkScheduledCount
+	}
+	if err := rsc.admitRun("request", rsr.GetTarget(), script, check); err != nil {
+		reply.Error = resourceExhaustedError(err)
+		return reply
+	}
+	scheduledID := rsc.scheduleScript(rsr.GetTarget(), script, at, now)
+
+	core.MarshalMessage(reply, &ScriptRunResponse{
+		ScheduledId: scheduledID,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestConfigHistoryList(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	keys, err := rsc.historyKeys()
+	if err != nil {
+		reply.Error = core.BusError(err)
+		rsc.host.LogError("listing config history", "error", err.Error())
+		return reply
+	}
+	chlr := &ConfigHistoryListResponse{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		cr := &ConfigRevision{}
+		if err := rsc.kvGetProto([]byte(keys[i]), cr); err != nil {
+			rsc.host.LogError("retrieving config revision", "key", keys[i], "error", err.Error())
+			continue
+		}
+		cr.Config = nil
+		chlr.Revisions = append(chlr.Revisions, cr)
+	}
+	core.MarshalMessage(reply, chlr)
+	return reply
+}
+
+func (rsc *Rosco) handleRequestConfigHistoryDiff(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	chdr := &ConfigHistoryDiffRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, chdr); reply.Error != nil {
+		return reply
+	}
+	var configs [2]*Config
+	for i, revision := range []uint64{chdr.GetFromRevision(), chdr.GetToRevision()} {
+		cr, err := rsc.loadRevision(revision)
+		if errors.Is(err, akcore.ErrNotFound) {
+			reply.Error = core.NotFoundError()
+			return reply
+		}
+		if err != nil {
+			reply.Error = core.BusError(err)
+			rsc.host.LogError("loading config revision", "revision", revision, "error", err.Error())
+			return reply
+		}
+		configs[i] = cr.GetConfig()
+	}
+	core.MarshalMessage(reply, &ConfigHistoryDiffResponse{
+		Changes: diffConfigs(configs[0], configs[1]),
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestExport(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	er := &ExportRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, er); reply.Error != nil {
+		return reply
+	}
+	doc, err := exportDocument(rsc.cfg, er.GetScriptIds(), er.GetTriggerIds())
+	if err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_NOT_FOUND),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+	b, err := doc.MarshalJSON()
+	if err != nil {
+		reply.Error = core.BusError(err)
+		rsc.host.LogError("marshalling export document", "error", err.Error())
+		return reply
+	}
+	core.MarshalMessage(reply, &ExportResponse{
+		Document: string(b),
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestScriptCompile(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	scr := &ScriptCompileRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, scr); reply.Error != nil {
+		return reply
+	}
+	script, textErrs := parseScriptText(scr.GetText())
+	if len(textErrs) > 0 {
+		details := make([]string, len(textErrs))
+		for i, te := range textErrs {
+			details[i] = fmt.Sprintf("%d:%d: %s", te.GetLine(), te.GetColumn(), te.GetMessage())
+		}
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(strings.Join(details, "; ")),
+		}
+	}
+	core.MarshalMessage(reply, &ScriptCompileResponse{
+		Script: script,
+		Errors: textErrs,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestScriptRender(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	srr := &ScriptRenderRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, srr); reply.Error != nil {
+		return reply
+	}
+	script := srr.GetScript()
+	if script == nil {
+		script = rsc.cfg.GetScripts()[srr.GetScriptId()]
 		if script == nil {
 			reply.Error = core.NotFoundError()
-			core.LogError("no script", "id", rsr.GetScriptId())
 			return reply
 		}
-		actions = script.GetActions()
 	}
-	if len(actions) == 0 {
+	text, err := renderScriptText(script)
+	if err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+	core.MarshalMessage(reply, &ScriptRenderResponse{
+		Text: text,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestScriptSimulate(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	ssr := &ScriptSimulateRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, ssr); reply.Error != nil {
+		return reply
+	}
+	script, busErr := rsc.requestedScript(ssr.GetScript(), ssr.GetScriptId())
+	if busErr != nil {
+		reply.Error = busErr
+		return reply
+	}
+	script, err := resolveRoles(script, ssr.GetRoles())
+	if err != nil {
+		reply.Error = &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+	tempo, _ := rsc.scriptTempo(script)
+	msgs, durationMS, err := simulateScript(ssr.GetTarget(), script, tempo, rsc.cfg.GetOutputs())
+	if err != nil {
 		reply.Error = &core.Error{
 			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
-			Detail: core.String("script has no actions"),
+			Detail: core.String(err.Error()),
+		}
+		return reply
+	}
+	core.MarshalMessage(reply, &ScriptSimulateResponse{
+		Messages:   msgs,
+		DurationMs: durationMS,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestSceneRecall(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	srr := &SceneRecallRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, srr); reply.Error != nil {
+		return reply
+	}
+	scene, present := rsc.cfg.GetScenes()[srr.GetSceneId()]
+	if !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	rsc.recallScene("request", scene, srr.GetDurationMs(), srr.GetCurve())
+	core.MarshalMessage(reply, &SceneRecallResponse{})
+	rsc.host.LogDebug("recalled scene", "id", srr.GetSceneId(), "duration_ms", srr.GetDurationMs())
+	return reply
+}
+
+// cueList returns the named cue list, or a not found error
+func (rsc *Rosco) cueList(name string) (*CueList, *core.Error) {
+	list, present := rsc.cfg.GetCueLists()[name]
+	if !present {
+		return nil, core.NotFoundError()
+	}
+	return list, nil
+}
+
+// cueMove applies a playback change to a cue list, reporting an error from
+// move as a bad request unless it's a refusal by the runner limits
+func (rsc *Rosco) cueMove(name string, move func(list *CueList, now int64) error) (*CueListStatus, *core.Error) {
+	list, busErr := rsc.cueList(name)
+	if busErr != nil {
+		return nil, busErr
+	}
+	now, err := rsc.host.CurrentTimeMillis()
+	if err != nil {
+		rsc.host.LogError("getting current time", "error", err.Error())
+		return nil, core.BusError(err)
+	}
+	if err := move(list, now); err != nil {
+		if errors.As(err, &refusedError{}) {
+			return nil, resourceExhaustedError(err)
+		}
+		return nil, &core.Error{
+			Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+			Detail: core.String(err.Error()),
 		}
+	}
+	return rsc.cueStatus(name), nil
+}
+
+func (rsc *Rosco) handleRequestCueGo(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	cgr := &CueGoRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, cgr); reply.Error != nil {
+		return reply
+	}
+	status, busErr := rsc.cueMove(cgr.GetCueList(), func(list *CueList, now int64) error {
+		return rsc.cueGo(cgr.GetCueList(), list, now)
+	})
+	if reply.Error = busErr; reply.Error != nil {
 		return reply
 	}
+	core.MarshalMessage(reply, &CueGoResponse{Status: status})
+	return reply
+}
 
-	rsc.runScript(rsr.GetTarget(), actions)
+func (rsc *Rosco) handleRequestCueBack(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	cbr := &CueBackRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, cbr); reply.Error != nil {
+		return reply
+	}
+	status, busErr := rsc.cueMove(cbr.GetCueList(), func(list *CueList, now int64) error {
+		return rsc.cueBack(cbr.GetCueList(), list, now)
+	})
+	if reply.Error = busErr; reply.Error != nil {
+		return reply
+	}
+	core.MarshalMessage(reply, &CueBackResponse{Status: status})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestCueJump(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	cjr := &CueJumpRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, cjr); reply.Error != nil {
+		return reply
+	}
+	status, busErr := rsc.cueMove(cjr.GetCueList(), func(list *CueList, _ int64) error {
+		return rsc.cueJump(cjr.GetCueList(), list, cjr.GetIndex())
+	})
+	if reply.Error = busErr; reply.Error != nil {
+		return reply
+	}
+	core.MarshalMessage(reply, &CueJumpResponse{Status: status})
+	return reply
+}
 
-	core.MarshalMessage(reply, &ScriptRunResponse{})
+func (rsc *Rosco) handleRequestCueStatus(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	csr := &CueStatusRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, csr); reply.Error != nil {
+		return reply
+	}
+	if _, reply.Error = rsc.cueList(csr.GetCueList()); reply.Error != nil {
+		return reply
+	}
+	core.MarshalMessage(reply, &CueStatusResponse{
+		Status: rsc.cueStatus(csr.GetCueList()),
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestMasterSet(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	msr := &MasterSetRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, msr); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.cfg.GetMasters()[msr.GetMaster()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	level, err := rsc.setMasterLevel(msr.GetMaster(), msr.GetLevel())
+	if err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &MasterSetResponse{
+		Level: level,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestMasterLevels(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	levels := make(map[string]float32, len(rsc.cfg.GetMasters()))
+	for name := range rsc.cfg.GetMasters() {
+		levels[name] = rsc.masterLevel(name)
+	}
+	core.MarshalMessage(reply, &MasterLevelsResponse{
+		Levels: levels,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestPanic(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	core.MarshalMessage(reply, &PanicResponse{
+		RunnersCancelled: rsc.emergencyStop("request"),
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestTempoSet(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	tsr := &TempoSetRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, tsr); reply.Error != nil {
+		return reply
+	}
+	if tsr.GetBpm() != 0 {
+		if err := validateBPM(tsr.GetBpm()); err != nil {
+			reply.Error = &core.Error{
+				Code:   int32(core.CommonErrorCode_BAD_REQUEST),
+				Detail: core.String(err.Error()),
+			}
+			return reply
+		}
+	}
+	tempo, err := rsc.setTempo(tsr.GetBpm(), tsr.GetBeatsPerBar())
+	if err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TempoSetResponse{
+		Tempo: tempo,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestTempoGet(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	core.MarshalMessage(reply, &TempoGetResponse{
+		Tempo: rsc.tempo,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestTempoTap(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	taps, err := rsc.tapTempo()
+	if err != nil {
+		reply.Error = core.BusError(err)
+		return reply
+	}
+	core.MarshalMessage(reply, &TempoTapResponse{
+		Tempo: rsc.tempo,
+		Taps:  taps,
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestRunnerList(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	core.MarshalMessage(reply, &RunnerListResponse{
+		Runners: rsc.runnerStatuses(),
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestScheduledRunList(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	core.MarshalMessage(reply, &ScheduledRunListResponse{
+		Runs: rsc.scheduledRunList(),
+	})
+	return reply
+}
+
+func (rsc *Rosco) handleRequestScheduledRunCancel(msg *core.BusMessage) *core.BusMessage {
+	reply := core.DefaultReply(msg)
+	srcr := &ScheduledRunCancelRequest{}
+	if reply.Error = core.UnmarshalMessage(msg, srcr); reply.Error != nil {
+		return reply
+	}
+	if _, present := rsc.scheduledRuns[srcr.GetId()]; !present {
+		reply.Error = core.NotFoundError()
+		return reply
+	}
+	delete(rsc.scheduledRuns, srcr.GetId())
+	rsc.runnersChanged = true
+	core.MarshalMessage(reply, &ScheduledRunCancelResponse{})
 	return reply
 }
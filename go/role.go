@@ -0,0 +1,77 @@
+package rosco
+
+import (
+	"fmt"
+	"sort"
+)
+
+// scriptRoles returns the sorted roles used by a script's actions and tracks
+func scriptRoles(script *Script) []string {
+	var roles []string
+	seen := map[string]bool{}
+	add := func(role string) {
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	for _, action := range script.GetActions() {
+		add(action.GetRole())
+	}
+	for _, track := range script.GetTracks() {
+		add(track.GetRole())
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// resolveRoles returns script with each action and track's role replaced by
+// the target roles maps it to. script isn't modified.
+func resolveRoles(script *Script, roles map[string]string) (*Script, error) {
+	if len(scriptRoles(script)) == 0 {
+		return script, nil
+	}
+	resolve := func(role string) (string, error) {
+		target := roles[role]
+		if target == "" {
+			return "", fmt.Errorf("no target for role %q", role)
+		}
+		return target, nil
+	}
+	resolved := script.CloneVT()
+	for _, action := range resolved.GetActions() {
+		if action.GetRole() == "" {
+			continue
+		}
+		target, err := resolve(action.GetRole())
+		if err != nil {
+			return nil, err
+		}
+		action.Target = target
+		action.Role = ""
+	}
+	for _, track := range resolved.GetTracks() {
+		if track.GetRole() == "" {
+			continue
+		}
+		target, err := resolve(track.GetRole())
+		if err != nil {
+			return nil, err
+		}
+		track.Target = target
+		track.Role = ""
+	}
+	return resolved, nil
+}
+
+// missingRoles returns the sorted roles used by script that roles doesn't map
+// to a target
+func missingRoles(script *Script, roles map[string]string) []string {
+	var missing []string
+	for _, role := range scriptRoles(script) {
+		if roles[role] == "" {
+			missing = append(missing, role)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,144 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionTargets(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	script := &Script{Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSet, Address: "/go", Values: float32Values(1)},
+		{Type: ScriptActionType_ActionTypeSet, Address: "/dimmer", Values: float32Values(1), Target: "lights"},
+		{Type: ScriptActionType_ActionTypeSet, Address: "/main/fader", Values: float32Values(1), Role: "audio"},
+	}}
+
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "cues",
+		Script: script,
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `no target for role "audio"`, reply.Error.GetDetail())
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "cues",
+		Script: script,
+		Roles:  map[string]string{"audio": "mixer"},
+	})
+	require.Nil(t, reply.Error)
+	for now := int64(1000); now < 1010; now++ {
+		tick(t, rsc, fh, now)
+	}
+	require.Equal(t, []sentMessage{
+		{target: "cues", address: "/go", values: float32Values(1)},
+		{target: "lights", address: "/dimmer", values: float32Values(1)},
+		{target: "mixer", address: "/main/fader", values: float32Values(1)},
+	}, fh.takeSent())
+	require.Equal(t, "audio", script.GetActions()[2].GetRole(), "the request's script isn't changed")
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts:  map[int32]*Script{1: script},
+			Triggers: map[string]*Trigger{"go": {Target: "cues", ScriptId: 1, Roles: map[string]string{"video": "vision"}}},
+		},
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `trigger "go": trigger has no target for role "audio"`, reply.Error.GetDetail())
+}
+
+func TestScriptTextTargets(t *testing.T) {
+	t.Parallel()
+	text := "set /dimmer 0.5 on \"lights\"\n" +
+		"fade /main/fader 0.0 -> 1.0 in 1s on role \"audio\"\n"
+	script, textErrs := parseScriptText(text)
+	require.Empty(t, textErrs)
+	require.Equal(t, "lights", script.GetActions()[0].GetTarget())
+	require.Equal(t, "audio", script.GetActions()[1].GetRole())
+	rendered, err := renderScriptText(script)
+	require.NoError(t, err)
+	require.Equal(t, text, rendered)
+
+	_, textErrs = parseScriptText("sleep 1s on \"lights\"\nset /a 1 on\n")
+	require.Equal(t, []*ScriptTextError{
+		{Line: 1, Column: 10, Message: "only set and fade can have a target or role"},
+		{Line: 2, Column: 10, Message: "expected on TARGET or on role ROLE"},
+	}, textErrs)
+}
+
+func TestScriptUpdateChecksTriggerRoles(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts:  map[int32]*Script{1: {Name: "show"}},
+			Triggers: map[string]*Trigger{"go": {Target: "cues", ScriptId: 1}},
+		},
+	})
+	require.Nil(t, reply.Error)
+	revision := rsc.cfg.GetRevision()
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_SCRIPT_UPDATE_REQ), &ScriptUpdateRequest{
+		ScriptId: 1,
+		Script: &Script{Name: "show", Actions: []*ScriptAction{
+			{Type: ScriptActionType_ActionTypeSet, Address: "/main/fader", Values: float32Values(1), Role: "audio"},
+		}},
+		BaseRevision: revision,
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `trigger "go": trigger has no target for role "audio"`, reply.Error.GetDetail())
+	require.Equal(t, revision, rsc.cfg.GetRevision())
+	require.Empty(t, rsc.cfg.GetScripts()[1].GetActions(), "the rejected script isn't saved")
+}
+
+func TestEditsCheckWholeConfig(t *testing.T) {
+	t.Parallel()
+	// a config stored before target groups were checked
+	stored := &Config{
+		Revision:     1,
+		Scripts:      map[int32]*Script{1: {Name: "show"}},
+		Triggers:     map[string]*Trigger{"go": {Target: "mixer", ScriptId: 1}},
+		TargetGroups: map[string]*TargetGroup{"empty": {}},
+	}
+	const detail = `target group "empty": target group has no members`
+	for _, tc := range []struct {
+		name    string
+		msgType MessageTypeCommand
+		req     core.Marshaller
+	}{
+		{
+			name:    "trigger create",
+			msgType: MessageTypeCommand_TRIGGER_CREATE_REQ,
+			req:     &TriggerCreateRequest{TriggerId: "new", Trigger: &Trigger{Target: "mixer", ScriptId: 1}, BaseRevision: 1},
+		},
+		{
+			name:    "trigger update",
+			msgType: MessageTypeCommand_TRIGGER_UPDATE_REQ,
+			req:     &TriggerUpdateRequest{TriggerId: "go", Trigger: &Trigger{Target: "lights", ScriptId: 1}, BaseRevision: 1},
+		},
+		{
+			name:    "cue list set",
+			msgType: MessageTypeCommand_CUE_LIST_SET_REQ,
+			req: &CueListSetRequest{CueList: "show", BaseRevision: 1, List: &CueList{Cues: []*Cue{
+				{Subject: &Cue_ScriptId{ScriptId: 1}, Target: "mixer"},
+			}}},
+		},
+		{
+			name:    "import",
+			msgType: MessageTypeCommand_IMPORT_REQ,
+			req:     &ImportRequest{Document: `{"scripts": {"1": {"name": "other"}}}`, BaseRevision: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rsc, _ := newTestRosco(t)
+			rsc.cfg = stored.CloneVT()
+			reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(tc.msgType), tc.req)
+			require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+			require.Equal(t, detail, reply.Error.GetDetail())
+			require.True(t, stored.EqualVT(rsc.cfg), "nothing is saved")
+		})
+	}
+}
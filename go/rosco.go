@@ -14,19 +14,58 @@ var (
 )
 
 type Rosco struct {
+	host        host
 	cfg         *Config
 	router      core.TopicRouter
 	runnerCount int
 	runners     map[int]*scriptRunner
+	// runnersChanged is set when runners or scheduledRuns have changed since
+	// they were last stored
+	runnersChanged bool
+	// runnersStepped is set when running scripts have moved on a step since
+	// the checkpoint written at checkpointedAt
+	runnersStepped bool
+	checkpointedAt int64
+	// scheduledRuns are script runs waiting to start, by ID
+	scheduledCount int32
+	scheduledRuns  map[int32]*scheduledRun
+	lastSent       map[oscDestination][]*OSCValue
+	limitedSent    map[oscDestination]limitedSend
+	cueStates      *CueListStates
+	pendingCues    map[string]*pendingCue
+	masterLevels   *MasterLevels
+	tempo          *Tempo
+	taps           []int64
 }
 
 func New() (*Rosco, error) {
+	return newRosco(coreHost{})
+}
+
+func newRosco(h host) (*Rosco, error) {
 	rsc := &Rosco{
-		runners: map[int]*scriptRunner{},
+		host:          h,
+		runners:       map[int]*scriptRunner{},
+		scheduledRuns: map[int32]*scheduledRun{},
+		lastSent:      map[oscDestination][]*OSCValue{},
+		limitedSent:   map[oscDestination]limitedSend{},
+		pendingCues:   map[string]*pendingCue{},
 	}
 	if err := rsc.loadConfig(); err != nil {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
+	if err := rsc.loadCueStates(); err != nil {
+		return nil, fmt.Errorf("loading cue state: %w", err)
+	}
+	if err := rsc.loadMasterLevels(); err != nil {
+		return nil, fmt.Errorf("loading master levels: %w", err)
+	}
+	if err := rsc.loadTempo(); err != nil {
+		return nil, fmt.Errorf("loading tempo: %w", err)
+	}
+	if err := rsc.loadRunnerCheckpoints(); err != nil {
+		return nil, fmt.Errorf("loading runner checkpoints: %w", err)
+	}
 
 	rsc.router = core.TopicRouter{
 		BusTopic_ROSCO_REQUEST.String(): rsc.handleRequests(),
@@ -35,17 +74,17 @@ func New() (*Rosco, error) {
 	}
 
 	for topic := range rsc.router {
-		core.LogDebug("subscribing", "topic", topic)
-		if err := core.Subscribe(topic); err != nil {
+		rsc.host.LogDebug("subscribing", "topic", topic)
+		if err := rsc.host.Subscribe(topic); err != nil {
 			return nil, fmt.Errorf("subscribing to topic %s: %w", topic, err)
 		}
 	}
 
-	token, err := svc.TimeNotifyEvery(1)
+	token, err := rsc.host.TimeNotifyEvery(1)
 	if err != nil {
 		return nil, fmt.Errorf("requesting periodic notification: %w", err)
 	}
-	core.LogDebug("notification token", "token", token)
+	rsc.host.LogDebug("notification token", "token", token)
 
 	return rsc, nil
 }
@@ -64,46 +103,139 @@ func (rsc *Rosco) handleDirect() core.TypeRouter {
 func (rsc *Rosco) handleTimeNotificationEvent(msg *core.BusMessage) *core.BusMessage {
 	var tn svc.TimeNotification
 	if err := core.UnmarshalMessage(msg, &tn); err != nil {
-		core.LogBusError("unmarshalling TimeNotification", err)
+		rsc.host.LogBusError("unmarshalling TimeNotification", err)
 		return nil
 	}
+	rsc.startScheduledRuns(tn.CurrentTimeMillis)
 	rsc.triggerScriptSteps(tn.CurrentTimeMillis)
+	rsc.runPendingCues(tn.CurrentTimeMillis)
+	rsc.writeRunnerCheckpoints(tn.CurrentTimeMillis)
 	return nil
 }
 
 func (rsc *Rosco) handleWebhookCallEvent(msg *core.BusMessage) *core.BusMessage {
 	var wce svc.WebhookCallEvent
 	if err := core.UnmarshalMessage(msg, &wce); err != nil {
-		core.LogBusError("unmarshalling WebhookCallEvent", err)
+		rsc.host.LogBusError("unmarshalling WebhookCallEvent", err)
 		return nil
 	}
-	trigger, present := rsc.cfg.Triggers[wce.GetParam("trigger")]
+	rsc.fireTrigger(wce.GetParam("trigger"))
+	return nil
+}
+
+// fireTrigger runs the script for the trigger with the given ID if the
+// trigger is armed
+func (rsc *Rosco) fireTrigger(triggerID string) {
+	trigger, present := rsc.cfg.Triggers[triggerID]
 	if !present {
-		core.LogError("bad trigger", "trigger", wce.GetParam("trigger"))
-		return nil
+		rsc.host.LogError("bad trigger", "trigger", triggerID)
+		return
+	}
+	if trigger.GetPanic() && !trigger.GetDisabled() {
+		rsc.emergencyStop("trigger " + triggerID)
+		return
+	}
+	if !rsc.triggerArmed(trigger) {
+		rsc.host.LogDebug("ignoring disarmed trigger", "trigger", triggerID)
+		return
+	}
+	if trigger.GetTapTempo() {
+		if _, err := rsc.tapTempo(); err != nil {
+			rsc.host.LogError("tapping tempo", "trigger", triggerID, "error", err.Error())
+		}
+		return
 	}
 	script, present := rsc.cfg.Scripts[trigger.GetScriptId()]
 	if !present {
-		core.LogError("bad script in trigger",
-			"trigger", wce.GetParam("trigger"),
+		rsc.host.LogError("bad script in trigger",
+			"trigger", triggerID,
 			"script_id", trigger.GetScriptId(),
 		)
-		return nil
+		return
 	}
-	rsc.runScript(trigger.GetTarget(), script.GetActions())
-	return nil
+	script, err := resolveRoles(script, trigger.GetRoles())
+	if err != nil {
+		rsc.host.LogError("bad roles in trigger", "trigger", triggerID, "error", err.Error())
+		return
+	}
+	err = rsc.admitRun("trigger "+triggerID, trigger.GetTarget(), script, func() error {
+		return rsc.checkRunnerCount(trigger.GetTarget(), script)
+	})
+	if err != nil {
+		return
+	}
+	rsc.startScript(trigger.GetTarget(), script)
+}
+
+// runsScript reports whether a trigger runs its script rather than panicking
+// or tapping the tempo
+func (t *Trigger) runsScript() bool {
+	return !t.GetPanic() && !t.GetTapTempo()
+}
+
+// triggerArmed reports whether a trigger should fire. A trigger fires when it
+// isn't disabled and, if it belongs to a group, that group is armed. A group
+// that hasn't been armed or disarmed yet, e.g. one brought in by an import,
+// counts as armed.
+func (rsc *Rosco) triggerArmed(trigger *Trigger) bool {
+	if trigger.GetDisabled() {
+		return false
+	}
+	group, present := rsc.cfg.GetTriggerGroups()[trigger.GetGroup()]
+	if !present {
+		return true
+	}
+	return group.GetArmed()
 }
 
 func (rsc *Rosco) loadConfig() error {
-	rsc.cfg = &Config{}
-	if err := core.KVGetProto(cfgKVKey, rsc.cfg); err != nil && !errors.Is(err, akcore.ErrNotFound) {
+	rsc.cfg = &Config{SchemaVersion: currentSchemaVersion}
+	b, err := rsc.host.KVGet(cfgKVKey)
+	if errors.Is(err, akcore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("retrieving config: %w", err)
 	}
+	// a stored config without a schema version predates versioning, so it
+	// mustn't pick up the current version from the default config
+	rsc.cfg = &Config{}
+	if err := rsc.cfg.UnmarshalVT(b); err != nil {
+		return fmt.Errorf("unmarshalling config: %w", err)
+	}
+	if rsc.cfg.GetSchemaVersion() == currentSchemaVersion {
+		return nil
+	}
+
+	// keep the config as it was stored in case the migration goes wrong
+	fromVersion := rsc.cfg.GetSchemaVersion()
+	if err := rsc.host.KVSet(backupKVKey(fromVersion), b); err != nil {
+		return fmt.Errorf("backing up config: %w", err)
+	}
+	if err := migrateConfig(rsc.cfg); err != nil {
+		return fmt.Errorf("migrating config: %w", err)
+	}
+	if err := rsc.writeCfg(rsc.cfg.CloneVT(), fmt.Sprintf("migrated from schema version %d", fromVersion)); err != nil {
+		return err
+	}
+	rsc.host.LogInfo("migrated config",
+		"from_version", fromVersion,
+		"to_version", currentSchemaVersion,
+	)
 	return nil
 }
 
-func (rsc *Rosco) writeCfg() {
-	if err := core.KVSetProto(cfgKVKey, rsc.cfg); err != nil {
-		core.LogError("writing config", "error", err.Error())
+// writeCfg stores cfg as the next config revision and records it in the
+// config history with the given comment. cfg must be a copy of the config
+// with the change made; it replaces the config only once it's stored.
+func (rsc *Rosco) writeCfg(cfg *Config, comment string) error {
+	cfg.Revision = rsc.cfg.GetRevision() + 1
+	cfg.SchemaVersion = currentSchemaVersion
+	if err := rsc.kvSetProto(cfgKVKey, cfg); err != nil {
+		rsc.host.LogError("writing config", "error", err.Error())
+		return fmt.Errorf("writing config: %w", err)
 	}
+	rsc.cfg = cfg
+	rsc.recordHistory(comment)
+	return nil
 }
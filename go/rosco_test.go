@@ -0,0 +1,206 @@
+package rosco
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+// setTestConfig stores a config with one script that sets /go and a trigger
+// for each of the given triggers that runs it
+func setTestConfig(t *testing.T, rsc *Rosco, triggers map[string]*Trigger, groups map[string]*TriggerGroup) {
+	t.Helper()
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts: map[int32]*Script{
+				1: {
+					Name: "go",
+					Actions: []*ScriptAction{
+						{
+							Type:    ScriptActionType_ActionTypeSet,
+							Address: "/go",
+							Values:  float32Values(1),
+						},
+					},
+				},
+			},
+			Triggers:      triggers,
+			TriggerGroups: groups,
+			Revision:      rsc.cfg.GetRevision(),
+		},
+	})
+	require.Nil(t, reply.Error)
+}
+
+func TestFireTrigger(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	setTestConfig(t, rsc, map[string]*Trigger{
+		"enabled":  {Target: "mixer", ScriptId: 1},
+		"disabled": {Target: "mixer", ScriptId: 1, Disabled: true},
+		"grouped":  {Target: "mixer", ScriptId: 1, Group: "show"},
+		"unlisted": {Target: "mixer", ScriptId: 1, Group: "unknown"},
+	}, map[string]*TriggerGroup{
+		"show": {Armed: false},
+	})
+
+	for _, tc := range []struct {
+		trigger string
+		fires   bool
+	}{
+		{trigger: "enabled", fires: true},
+		{trigger: "disabled"},
+		{trigger: "grouped"},
+		{trigger: "unlisted", fires: true},
+		{trigger: "missing"},
+	} {
+		rsc.fireTrigger(tc.trigger)
+		tick(t, rsc, fh, fh.now+1)
+		if tc.fires {
+			require.Len(t, fh.takeSent(), 1, tc.trigger)
+		} else {
+			require.Empty(t, fh.takeSent(), tc.trigger)
+		}
+	}
+
+	// arming and enabling don't need a base revision
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_TRIGGER_GROUP_ARM_REQ), &TriggerGroupArmRequest{
+		Group: "show",
+		Armed: true,
+	})
+	require.Nil(t, reply.Error)
+	rsc.fireTrigger("grouped")
+	tick(t, rsc, fh, fh.now+1)
+	require.Len(t, fh.takeSent(), 1)
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_TRIGGER_ENABLE_REQ), &TriggerEnableRequest{
+		TriggerId: "disabled",
+		Enabled:   true,
+	})
+	require.Nil(t, reply.Error)
+	rsc.fireTrigger("disabled")
+	tick(t, rsc, fh, fh.now+1)
+	require.Len(t, fh.takeSent(), 1)
+
+	for _, tc := range []struct {
+		msgType MessageTypeCommand
+		req     core.Marshaller
+	}{
+		{MessageTypeCommand_TRIGGER_GROUP_ARM_REQ, &TriggerGroupArmRequest{Group: "show", BaseRevision: 1}},
+		{MessageTypeCommand_TRIGGER_ENABLE_REQ, &TriggerEnableRequest{TriggerId: "disabled", BaseRevision: 1}},
+	} {
+		reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(tc.msgType), tc.req)
+		require.Equal(t, int32(ErrorCode_CONFLICT), reply.Error.GetCode(), "a stale revision given is refused")
+	}
+	rsc.fireTrigger("grouped")
+	rsc.fireTrigger("disabled")
+	tick(t, rsc, fh, fh.now+1)
+	require.Len(t, fh.takeSent(), 2)
+}
+
+func TestConfigSetGet(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	setTestConfig(t, rsc, map[string]*Trigger{
+		"go": {Target: "mixer", ScriptId: 1},
+	}, nil)
+
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_CONFIG_GET_REQ), &ConfigGetRequest{})
+	require.Nil(t, reply.Error)
+	cgr := &ConfigGetResponse{}
+	require.NoError(t, cgr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, uint64(1), cgr.GetConfig().GetRevision())
+	require.Equal(t, currentSchemaVersion, cgr.GetConfig().GetSchemaVersion())
+	require.Equal(t, "go", cgr.GetConfig().GetScripts()[1].GetName())
+	require.Contains(t, cgr.GetConfig().GetTriggers(), "go")
+	require.Contains(t, cgr.GetTimelines(), int32(1))
+
+	// a new Rosco on the same host loads the stored config
+	reloaded, err := newRosco(fh)
+	require.NoError(t, err)
+	require.True(t, cgr.GetConfig().EqualVT(reloaded.cfg))
+
+	_, err = rsc.loadRevision(1)
+	require.NoError(t, err)
+}
+
+func TestErrorReplies(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	setTestConfig(t, rsc, map[string]*Trigger{
+		"go": {Target: "mixer", ScriptId: 1},
+	}, nil)
+
+	for _, tc := range []struct {
+		name    string
+		topic   BusTopic
+		msgType int32
+		req     core.Marshaller
+		code    int32
+	}{
+		{
+			name:    "missing script",
+			topic:   BusTopic_ROSCO_REQUEST,
+			msgType: int32(MessageTypeRequest_SCRIPT_RUN_REQ),
+			req:     &ScriptRunRequest{Target: "mixer", ScriptId: 2},
+			code:    int32(core.CommonErrorCode_NOT_FOUND),
+		},
+		{
+			name:    "script in use",
+			topic:   BusTopic_ROSCO_COMMAND,
+			msgType: int32(MessageTypeCommand_SCRIPT_DELETE_REQ),
+			req:     &ScriptDeleteRequest{ScriptId: 1, BaseRevision: 1},
+			code:    int32(core.CommonErrorCode_BAD_REQUEST),
+		},
+		{
+			name:    "stale revision",
+			topic:   BusTopic_ROSCO_COMMAND,
+			msgType: int32(MessageTypeCommand_SCRIPT_DELETE_REQ),
+			req:     &ScriptDeleteRequest{ScriptId: 1, BaseRevision: 0},
+			code:    int32(ErrorCode_CONFLICT),
+		},
+		{
+			name:    "invalid config",
+			topic:   BusTopic_ROSCO_COMMAND,
+			msgType: int32(MessageTypeCommand_CONFIG_SET_REQ),
+			req: &ConfigSetRequest{Config: &Config{
+				Triggers: map[string]*Trigger{"go": {Target: "mixer", ScriptId: 2}},
+				Revision: 1,
+			}},
+			code: int32(core.CommonErrorCode_BAD_REQUEST),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := busRequest(t, rsc, tc.topic, tc.msgType, tc.req)
+			require.NotNil(t, reply.Error)
+			require.Equal(t, tc.code, reply.Error.GetCode())
+		})
+	}
+	require.Equal(t, uint64(1), rsc.cfg.GetRevision(), "failed requests shouldn't change the config")
+}
+
+func TestConfigWriteFailureKeepsConfig(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	setTestConfig(t, rsc, map[string]*Trigger{"go": {Target: "mixer", ScriptId: 1}}, nil)
+	revision := rsc.cfg.GetRevision()
+
+	fh.kvSetErr = errors.New("disk full")
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_TRIGGER_ENABLE_REQ), &TriggerEnableRequest{
+		TriggerId:    "go",
+		BaseRevision: revision,
+	})
+	require.NotNil(t, reply.Error, "a failed write is reported")
+	require.Equal(t, revision, rsc.cfg.GetRevision(), "the revision isn't bumped when the write fails")
+	require.False(t, rsc.cfg.GetTriggers()["go"].GetDisabled(), "the change doesn't take effect")
+
+	fh.kvSetErr = nil
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_TRIGGER_ENABLE_REQ), &TriggerEnableRequest{
+		TriggerId:    "go",
+		BaseRevision: revision,
+	})
+	require.Nil(t, reply.Error)
+	require.Equal(t, revision+1, rsc.cfg.GetRevision())
+}
@@ -0,0 +1,172 @@
+package rosco
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/autonomouskoi/core-tinygo"
+)
+
+const (
+	defaultMaxRunners          = 100
+	defaultMaxRunnersPerTarget = 20
+	defaultMaxScriptActions    = 1000
+)
+
+// maxRunners is how many scripts may run at once
+func (rl *RunnerLimits) maxRunners() int {
+	if rl.GetMaxRunners() == 0 {
+		return defaultMaxRunners
+	}
+	return int(rl.GetMaxRunners())
+}
+
+// targetMaxRunners is how many scripts may run at once on target
+func (rl *RunnerLimits) targetMaxRunners(target string) int {
+	if limit := rl.GetTargetMaxRunners()[target]; limit != 0 {
+		return int(limit)
+	}
+	if rl.GetMaxRunnersPerTarget() == 0 {
+		return defaultMaxRunnersPerTarget
+	}
+	return int(rl.GetMaxRunnersPerTarget())
+}
+
+// maxScriptActions is how many actions and keyframes a script may have
+func (rl *RunnerLimits) maxScriptActions() int {
+	if rl.GetMaxScriptActions() == 0 {
+		return defaultMaxScriptActions
+	}
+	return int(rl.GetMaxScriptActions())
+}
+
+// checkScriptSize returns an error if script has more actions and keyframes
+// than the limits allow
+func checkScriptSize(script *Script, limits *RunnerLimits) error {
+	size := len(script.GetActions())
+	for _, track := range script.GetTracks() {
+		size += len(track.GetKeyframes())
+	}
+	if size > limits.maxScriptActions() {
+		return fmt.Errorf("script has %d actions and keyframes, more than the limit of %d",
+			size, limits.maxScriptActions(),
+		)
+	}
+	return nil
+}
+
+// sendTargets returns the targets a script run on target sends to, with
+// target groups replaced by their members as they are when sending
+func (rsc *Rosco) sendTargets(target string, actions []*ScriptAction, tracks []*KeyframeTrack) []string {
+	targets := map[string]bool{}
+	add := func(target string) {
+		for _, member := range rsc.groupTargets(target) {
+			targets[member] = true
+		}
+	}
+	add(target)
+	for _, action := range actions {
+		if action.GetTarget() != "" {
+			add(action.GetTarget())
+		}
+	}
+	for _, track := range tracks {
+		if track.GetTarget() != "" {
+			add(track.GetTarget())
+		}
+	}
+	return slices.Sorted(maps.Keys(targets))
+}
+
+// runnerTargets returns the targets a running script sends to
+func (rsc *Rosco) runnerTargets(sr *scriptRunner) []string {
+	if sr.script != nil {
+		return rsc.sendTargets(sr.target, sr.script.GetActions(), sr.script.GetTracks())
+	}
+	return rsc.sendTargets(sr.target, sr.steps, sr.tracks)
+}
+
+// checkRunnerCount returns an error if script can't start on target without
+// going over the runner limits. Scripts count against every target they send
+// to.
+func (rsc *Rosco) checkRunnerCount(target string, script *Script) error {
+	limits := rsc.cfg.GetRunnerLimits()
+	if len(rsc.runners) >= limits.maxRunners() {
+		return fmt.Errorf("%d scripts are running, the most allowed", len(rsc.runners))
+	}
+	onTarget := map[string]int{}
+	for _, sr := range rsc.runners {
+		for _, target := range rsc.runnerTargets(sr) {
+			onTarget[target]++
+		}
+	}
+	for _, target := range rsc.sendTargets(target, script.GetActions(), script.GetTracks()) {
+		if onTarget[target] >= limits.targetMaxRunners(target) {
+			return fmt.Errorf("%d scripts are running on target %q, the most allowed", onTarget[target], target)
+		}
+	}
+	return nil
+}
+
+// checkScheduledCount returns an error if another run can't be scheduled
+// without going over the runner limits
+func (rsc *Rosco) checkScheduledCount() error {
+	limits := rsc.cfg.GetRunnerLimits()
+	if len(rsc.scheduledRuns) >= limits.maxRunners() {
+		return fmt.Errorf("%d runs are scheduled, the most allowed", len(rsc.scheduledRuns))
+	}
+	return nil
+}
+
+// admitRun reports whether check allows script to run on target. If it
+// doesn't, the refusal is logged and published as an event.
+func (rsc *Rosco) admitRun(source, target string, script *Script, check func() error) error {
+	err := check()
+	if err == nil {
+		return nil
+	}
+	rsc.host.LogError("refusing to run script",
+		"source", source,
+		"target", target,
+		"name", script.GetName(),
+		"error", err.Error(),
+	)
+
+	now, nowErr := rsc.host.CurrentTimeMillis()
+	if nowErr != nil {
+		rsc.host.LogError("getting current time", "error", nowErr.Error())
+	}
+	msg := &core.BusMessage{
+		Topic: BusTopic_ROSCO_EVENT.String(),
+		Type:  int32(MessageTypeEvent_RUNNER_LIMIT_EVENT),
+	}
+	core.MarshalMessage(msg, &RunnerLimitEvent{
+		TimestampMs: now,
+		Source:      source,
+		Target:      target,
+		ScriptName:  script.GetName(),
+		Message:     err.Error(),
+	})
+	if msg.Error == nil {
+		if err := rsc.host.Send(msg); err != nil {
+			rsc.host.LogError("sending runner limit event", "error", err.Error())
+		}
+	}
+	return err
+}
+
+// a refusedError is a run refused by the runner limits, passed back through
+// code that reports other errors as bad requests
+type refusedError struct {
+	error
+}
+
+// resourceExhaustedError reports a runner limit being reached
+func resourceExhaustedError(err error) *core.Error {
+	return &core.Error{
+		Code:           int32(ErrorCode_RESOURCE_EXHAUSTED),
+		NotCommonError: true,
+		Detail:         core.String(err.Error()),
+	}
+}
@@ -0,0 +1,230 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerLimits(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	long := &Script{Name: "long", Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 10_000},
+	}}
+	big := &Script{Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1},
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1},
+	}, Tracks: []*KeyframeTrack{
+		{Address: "/a", Keyframes: []*Keyframe{{TimeMs: 0}, {TimeMs: 1}}},
+	}}
+	cfg := &Config{
+		Scripts: map[int32]*Script{1: long},
+		Triggers: map[string]*Trigger{
+			"go":   {Target: "mixer", ScriptId: 1},
+			"stop": {Panic: true},
+		},
+		SafeStates: map[string]*SafeState{
+			"mixer": {Actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeSet, Address: "/main/mute", Values: float32Values(1)},
+			}},
+		},
+		RunnerLimits: &RunnerLimits{
+			MaxRunners:          3,
+			MaxRunnersPerTarget: 2,
+			TargetMaxRunners:    map[string]uint32{"lights": 1},
+			MaxScriptActions:    3,
+		},
+	}
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: cfg,
+	})
+	require.Nil(t, reply.Error)
+
+	cfg = cfg.CloneVT()
+	cfg.Revision = rsc.cfg.GetRevision()
+	cfg.Scripts[2] = big
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: cfg,
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `script 2: script has both actions and tracks; `+
+		`script 2: script has 4 actions and keyframes, more than the limit of 3`,
+		reply.Error.GetDetail(),
+	)
+
+	run := func(target string, srr *ScriptRunRequest) *core.Error {
+		t.Helper()
+		if srr.GetScript() == nil {
+			srr.Script = long
+		}
+		srr.Target = target
+		return busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), srr).Error
+	}
+	busErr := run("mixer", &ScriptRunRequest{Script: &Script{
+		Actions: append(big.GetActions(), big.GetActions()...),
+	}})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), busErr.GetCode())
+	require.Equal(t, "script has 4 actions and keyframes, more than the limit of 3", busErr.GetDetail())
+
+	rsc.fireTrigger("go")
+	rsc.fireTrigger("go")
+	rsc.fireTrigger("go")
+	rsc.fireTrigger("go")
+	require.Len(t, rsc.runners, 2, "only 2 scripts may run on a target")
+	busErr = run("mixer", &ScriptRunRequest{})
+	require.Equal(t, int32(ErrorCode_RESOURCE_EXHAUSTED), busErr.GetCode())
+	require.True(t, busErr.GetNotCommonError())
+	require.Equal(t, `2 scripts are running on target "mixer", the most allowed`, busErr.GetDetail())
+
+	require.Nil(t, run("lights", &ScriptRunRequest{}))
+	busErr = run("lights", &ScriptRunRequest{})
+	require.Equal(t, `3 scripts are running, the most allowed`, busErr.GetDetail(), "the global limit is checked first")
+	require.Len(t, rsc.runners, 3)
+
+	var events []*RunnerLimitEvent
+	for _, msg := range fh.published {
+		if msg.GetType() == int32(MessageTypeEvent_RUNNER_LIMIT_EVENT) {
+			rle := &RunnerLimitEvent{}
+			require.NoError(t, rle.UnmarshalVT(msg.GetMessage()))
+			events = append(events, rle)
+		}
+	}
+	onMixer := `2 scripts are running on target "mixer", the most allowed`
+	require.Equal(t, []*RunnerLimitEvent{
+		{
+			TimestampMs: 1,
+			Source:      "request",
+			Target:      "mixer",
+			Message:     "script has 4 actions and keyframes, more than the limit of 3",
+		},
+		{TimestampMs: 1, Source: "trigger go", Target: "mixer", ScriptName: "long", Message: onMixer},
+		{TimestampMs: 1, Source: "trigger go", Target: "mixer", ScriptName: "long", Message: onMixer},
+		{TimestampMs: 1, Source: "request", Target: "mixer", ScriptName: "long", Message: onMixer},
+		{
+			TimestampMs: 1,
+			Source:      "request",
+			Target:      "lights",
+			ScriptName:  "long",
+			Message:     `3 scripts are running, the most allowed`,
+		},
+	}, events, "every refusal is published")
+
+	for i := 0; i < 3; i++ {
+		require.Nil(t, run("mixer", &ScriptRunRequest{DelayMs: 1000}))
+	}
+	busErr = run("mixer", &ScriptRunRequest{DelayMs: 1000})
+	require.Equal(t, `3 runs are scheduled, the most allowed`, busErr.GetDetail())
+
+	fh.takeSent()
+	rsc.fireTrigger("stop")
+	require.Empty(t, rsc.runners)
+	require.Equal(t, []sentMessage{
+		{target: "mixer", address: "/main/mute", values: float32Values(1)},
+	}, fh.takeSent(), "panics aren't limited")
+}
+
+func TestRunnerLimitsCountSentTargets(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			TargetGroups: map[string]*TargetGroup{
+				"mixers": {Members: []*TargetGroupMember{{Target: "main"}, {Target: "backup"}}},
+			},
+			RunnerLimits: &RunnerLimits{MaxRunnersPerTarget: 1},
+		},
+	})
+	require.Nil(t, reply.Error)
+	long := func(actionTarget string) *Script {
+		return &Script{Name: "long", Actions: []*ScriptAction{
+			{Type: ScriptActionType_ActionTypeSet, Address: "/a", Values: float32Values(1), Target: actionTarget},
+			{Type: ScriptActionType_ActionTypeSleep, DurationMs: 10_000},
+		}}
+	}
+	run := func(target string, script *Script) *core.Error {
+		t.Helper()
+		return busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+			Target: target,
+			Script: script,
+		}).Error
+	}
+
+	require.Nil(t, run("mixers", long("")))
+	busErr := run("main", long(""))
+	require.Equal(t, int32(ErrorCode_RESOURCE_EXHAUSTED), busErr.GetCode())
+	require.Equal(t, `1 scripts are running on target "main", the most allowed`, busErr.GetDetail(),
+		"a group's runs count against its members",
+	)
+	busErr = run("lights", long("backup"))
+	require.Equal(t, `1 scripts are running on target "backup", the most allowed`, busErr.GetDetail(),
+		"actions count against their own targets",
+	)
+	require.Nil(t, run("lights", long("")))
+}
+
+func TestScriptSizeLimitOnSave(t *testing.T) {
+	t.Parallel()
+	big := &Script{Name: "big", Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1},
+		{Type: ScriptActionType_ActionTypeSleep, DurationMs: 1},
+	}}
+	from, _ := newTestRosco(t)
+	reply := busRequest(t, from, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{Scripts: map[int32]*Script{1: big}},
+	})
+	require.Nil(t, reply.Error)
+	doc := exportConfig(t, from)
+
+	rsc, _ := newTestRosco(t)
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scripts:      map[int32]*Script{1: {Name: "small"}},
+			RunnerLimits: &RunnerLimits{MaxScriptActions: 1},
+		},
+	})
+	require.Nil(t, reply.Error)
+	revision := rsc.cfg.GetRevision()
+
+	for _, tc := range []struct {
+		name    string
+		msgType MessageTypeCommand
+		req     core.Marshaller
+		detail  string
+	}{
+		{
+			name:    "create",
+			msgType: MessageTypeCommand_SCRIPT_CREATE_REQ,
+			req:     &ScriptCreateRequest{Script: big, BaseRevision: revision},
+			detail:  "script 2: script has 2 actions and keyframes, more than the limit of 1",
+		},
+		{
+			name:    "update",
+			msgType: MessageTypeCommand_SCRIPT_UPDATE_REQ,
+			req:     &ScriptUpdateRequest{ScriptId: 1, Script: big, BaseRevision: revision},
+			detail:  "script 1: script has 2 actions and keyframes, more than the limit of 1",
+		},
+		{
+			name:    "import",
+			msgType: MessageTypeCommand_IMPORT_REQ,
+			req:     &ImportRequest{Document: doc, BaseRevision: revision},
+			detail:  "script 1: script has 2 actions and keyframes, more than the limit of 1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(tc.msgType), tc.req)
+			require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+			require.Equal(t, tc.detail, reply.Error.GetDetail())
+			require.Equal(t, revision, rsc.cfg.GetRevision())
+		})
+	}
+}
+
+func TestDiffRunnerLimits(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, diffConfigs(&Config{}, &Config{}), "runner limits in neither config are unchanged")
+	require.Equal(t, []*ConfigChange{
+		{Subject: &ConfigChange_RunnerLimits{RunnerLimits: true}, Change: ConfigChangeType_CHANGE_ADDED},
+	}, diffConfigs(&Config{}, &Config{RunnerLimits: &RunnerLimits{MaxRunners: 1}}))
+}
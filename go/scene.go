@@ -0,0 +1,123 @@
+package rosco
+
+import (
+	"slices"
+	"strings"
+)
+
+// an oscDestination is an address on a target
+type oscDestination struct {
+	target, address string
+}
+
+// recordSent keeps the values sent to an address, after limits but before
+// masters, so they can be captured in a scene, faded from or sent again when
+// a master changes
+func (rsc *Rosco) recordSent(target, address string, values []*OSCValue) {
+	rsc.lastSent[oscDestination{target: target, address: address}] = values
+}
+
+// captureScene builds a scene from the values last sent to the given
+// targets, or to every target if none are given. Target groups capture their
+// members. Values are ordered by target, then address.
+func (rsc *Rosco) captureScene(name string, targets []string) *Scene {
+	scene := &Scene{Name: name}
+	var expanded []string
+	for _, target := range targets {
+		expanded = append(expanded, rsc.groupTargets(target)...)
+	}
+	targets = expanded
+	for dest, values := range rsc.lastSent {
+		if len(targets) > 0 && !slices.Contains(targets, dest.target) {
+			continue
+		}
+		scene.Values = append(scene.Values, &SceneValue{
+			Target:  dest.target,
+			Address: dest.address,
+			Values:  values,
+			Snaps:   !isSingleFloat32(values),
+		})
+	}
+	slices.SortFunc(scene.Values, func(a, b *SceneValue) int {
+		if c := strings.Compare(a.GetTarget(), b.GetTarget()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.GetAddress(), b.GetAddress())
+	})
+	return scene
+}
+
+// recallScene sends a scene's values. If durationMS isn't 0, addresses with a
+// single float32 value that were last sent a single float32 value fade to the
+// scene's value along curve. Each target's fades run together as one
+// runner, admitted against the runner limits on behalf of source. Everything
+// else, including the fades of a target that's refused a runner, is sent
+// immediately.
+func (rsc *Rosco) recallScene(source string, scene *Scene, durationMS uint32, curve FadeCurve) {
+	fades := map[string]*Script{}
+	faded := map[string][]*SceneValue{}
+	var targets []string
+	for _, sv := range scene.GetValues() {
+		if durationMS > 0 {
+			from := rsc.lastSent[oscDestination{target: sv.GetTarget(), address: sv.GetAddress()}]
+			if isSingleFloat32(from) && isSingleFloat32(sv.GetValues()) {
+				fade, present := fades[sv.GetTarget()]
+				if !present {
+					fade = &Script{Name: scene.GetName()}
+					fades[sv.GetTarget()] = fade
+					targets = append(targets, sv.GetTarget())
+				}
+				fade.Tracks = append(fade.Tracks, &KeyframeTrack{
+					Address: sv.GetAddress(),
+					Keyframes: []*Keyframe{
+						{Value: from[0].GetFloat32(), Curve: curve},
+						{TimeMs: durationMS, Value: sv.GetValues()[0].GetFloat32()},
+					},
+				})
+				faded[sv.GetTarget()] = append(faded[sv.GetTarget()], sv)
+				continue
+			}
+		}
+		rsc.sendOSC(sv.GetTarget(), sv.GetAddress(), sv.GetValues())
+	}
+	for _, target := range targets {
+		err := rsc.admitRun(source, target, fades[target], func() error {
+			return rsc.checkRunnerCount(target, fades[target])
+		})
+		if err == nil {
+			rsc.startScript(target, fades[target])
+			continue
+		}
+		for _, sv := range faded[target] {
+			rsc.sendOSC(sv.GetTarget(), sv.GetAddress(), sv.GetValues())
+		}
+	}
+}
+
+// flagSnaps marks the values in cfg's scenes that a crossfade can't fade to
+func flagSnaps(cfg *Config) {
+	for _, scene := range cfg.GetScenes() {
+		for _, sv := range scene.GetValues() {
+			sv.Snaps = !isSingleFloat32(sv.GetValues())
+		}
+	}
+}
+
+func isSingleFloat32(values []*OSCValue) bool {
+	if len(values) != 1 {
+		return false
+	}
+	_, ok := values[0].GetValue().(*OSCValue_Float32)
+	return ok
+}
+
+// nextSceneID returns a scene ID unused in cfg
+func nextSceneID(cfg *Config) int32 {
+	var max int32
+	for id := range cfg.GetScenes() {
+		if id > max {
+			max = id
+		}
+	}
+	return max + 1
+}
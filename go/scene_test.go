@@ -0,0 +1,125 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSceneCaptureRecall(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	intValue := []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}
+	rsc.sendOSC("mixer", "/ch/02/on", intValue)
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0))
+	rsc.sendOSC("lights", "/dimmer", float32Values(1))
+	fh.takeSent()
+
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_SCENE_CAPTURE_REQ), &SceneCaptureRequest{
+		Name:    "look",
+		Targets: []string{"mixer"},
+	})
+	require.Nil(t, reply.Error)
+	scr := &SceneCaptureResponse{}
+	require.NoError(t, scr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, int32(1), scr.GetSceneId())
+	require.Equal(t, []*SceneValue{
+		{Target: "mixer", Address: "/ch/01/fader", Values: float32Values(0)},
+		{Target: "mixer", Address: "/ch/02/on", Values: intValue, Snaps: true},
+	}, scr.GetScene().GetValues(), "values that can't crossfade are flagged")
+	require.True(t, scr.GetScene().EqualVT(rsc.cfg.GetScenes()[1]))
+
+	// move away from the scene, then crossfade back
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0.5))
+	fh.takeSent()
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCENE_RECALL_REQ), &SceneRecallRequest{
+		SceneId:    1,
+		DurationMs: 100,
+	})
+	require.Nil(t, reply.Error)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/ch/02/on", values: intValue}}, fh.takeSent(),
+		"non-float values are sent immediately",
+	)
+	for now := int64(1000); now < 1200; now++ {
+		tick(t, rsc, fh, now)
+	}
+	sent := fh.takeSent()
+	require.Greater(t, len(sent), 2)
+	require.Equal(t, float32Values(0.5), sent[0].values)
+	require.Equal(t, float32Values(0), sent[len(sent)-1].values)
+
+	// an instant recall sends everything at once
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCENE_RECALL_REQ), &SceneRecallRequest{
+		SceneId: 1,
+	})
+	require.Nil(t, reply.Error)
+	require.Len(t, fh.takeSent(), 2)
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCENE_RECALL_REQ), &SceneRecallRequest{
+		SceneId: 2,
+	})
+	require.NotNil(t, reply.Error)
+}
+
+func TestSceneCrossfadeRunnerLimits(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scenes: map[int32]*Scene{1: {Name: "look", Values: []*SceneValue{
+				{Target: "lights", Address: "/dimmer", Values: float32Values(1)},
+				{Target: "mixer", Address: "/ch/01/fader", Values: float32Values(1)},
+				{Target: "mixer", Address: "/ch/02/fader", Values: float32Values(1)},
+			}}},
+			RunnerLimits: &RunnerLimits{MaxRunnersPerTarget: 1},
+		},
+	})
+	require.Nil(t, reply.Error)
+	require.False(t, rsc.cfg.GetScenes()[1].GetValues()[0].GetSnaps())
+	rsc.sendOSC("lights", "/dimmer", float32Values(0))
+	rsc.sendOSC("mixer", "/ch/01/fader", float32Values(0))
+	rsc.sendOSC("mixer", "/ch/02/fader", float32Values(0))
+	rsc.runScript("lights", []*ScriptAction{{Type: ScriptActionType_ActionTypeSleep, DurationMs: 10_000}})
+	fh.takeSent()
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCENE_RECALL_REQ), &SceneRecallRequest{
+		SceneId:    1,
+		DurationMs: 100,
+	})
+	require.Nil(t, reply.Error)
+	require.Equal(t, []sentMessage{{target: "lights", address: "/dimmer", values: float32Values(1)}}, fh.takeSent(),
+		"a target that's refused a runner gets the scene's values immediately",
+	)
+	require.Len(t, rsc.runners, 2, "a target's fades share one runner")
+	for now := int64(1000); now < 1200; now++ {
+		tick(t, rsc, fh, now)
+	}
+	last := map[string][]*OSCValue{}
+	for _, sm := range fh.takeSent() {
+		last[sm.address] = sm.values
+	}
+	require.Equal(t, map[string][]*OSCValue{
+		"/ch/01/fader": float32Values(1),
+		"/ch/02/fader": float32Values(1),
+	}, last)
+}
+
+func TestSceneSnapsFlagged(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Scenes: map[int32]*Scene{1: {Name: "look", Values: []*SceneValue{
+				{Target: "lights", Address: "/dimmer", Values: float32Values(1), Snaps: true},
+				{Target: "lights", Address: "/color", Values: float32Values(1, 0.5)},
+				{Target: "mixer", Address: "/ch/01/on", Values: []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}},
+			}}},
+		},
+	})
+	require.Nil(t, reply.Error)
+	var snaps []bool
+	for _, sv := range rsc.cfg.GetScenes()[1].GetValues() {
+		snaps = append(snaps, sv.GetSnaps())
+	}
+	require.Equal(t, []bool{false, true, true}, snaps)
+}
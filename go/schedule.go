@@ -0,0 +1,85 @@
+package rosco
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// a scheduledRun is a script run waiting to start at a set time. The script
+// is resolved when the run is requested, so config changes while it waits
+// don't change what runs.
+type scheduledRun struct {
+	target string
+	script *Script
+	at     int64
+}
+
+// scheduleScript starts script on target at, or now if at has passed. It
+// returns the ID of the scheduled run, 0 if the script started immediately.
+func (rsc *Rosco) scheduleScript(target string, script *Script, at, now int64) int32 {
+	if at <= now {
+		rsc.startScript(target, script)
+		return 0
+	}
+	rsc.scheduledCount++
+	rsc.runnersChanged = true
+	rsc.scheduledRuns[rsc.scheduledCount] = &scheduledRun{
+		target: target,
+		script: script,
+		at:     at,
+	}
+	return rsc.scheduledCount
+}
+
+// scheduledIDs are the IDs of the runs waiting to start, soonest first
+func (rsc *Rosco) scheduledIDs() []int32 {
+	ids := make([]int32, 0, len(rsc.scheduledRuns))
+	for id := range rsc.scheduledRuns {
+		ids = append(ids, id)
+	}
+	slices.SortFunc(ids, func(a, b int32) int {
+		if c := cmp.Compare(rsc.scheduledRuns[a].at, rsc.scheduledRuns[b].at); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return ids
+}
+
+// startScheduledRuns starts the runs that are due at now. Their steps are
+// timed from when they were scheduled to start, not from now.
+func (rsc *Rosco) startScheduledRuns(now int64) {
+	for _, id := range rsc.scheduledIDs() {
+		run := rsc.scheduledRuns[id]
+		if run.at > now {
+			return
+		}
+		delete(rsc.scheduledRuns, id)
+		rsc.runnersChanged = true
+		err := rsc.admitRun(fmt.Sprintf("scheduled run %d", id), run.target, run.script, func() error {
+			return rsc.checkRunnerCount(run.target, run.script)
+		})
+		if err != nil {
+			continue
+		}
+		sr := rsc.startScript(run.target, run.script)
+		sr.started, sr.startedAt, sr.due = true, run.at, run.at
+	}
+}
+
+// scheduledRunList describes the runs waiting to start, soonest first
+func (rsc *Rosco) scheduledRunList() []*ScheduledRun {
+	ids := rsc.scheduledIDs()
+	runs := make([]*ScheduledRun, 0, len(ids))
+	for _, id := range ids {
+		run := rsc.scheduledRuns[id]
+		runs = append(runs, &ScheduledRun{
+			Id:         id,
+			Target:     run.target,
+			ScriptName: run.script.GetName(),
+			StartAtMs:  run.at,
+		})
+	}
+	return runs
+}
@@ -0,0 +1,86 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledRuns(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	run := func(srr *ScriptRunRequest) int32 {
+		t.Helper()
+		srr.Script = &Script{Name: srr.GetTarget(), Actions: []*ScriptAction{
+			{Type: ScriptActionType_ActionTypeSet, Address: "/go", Values: float32Values(1)},
+			{Type: ScriptActionType_ActionTypeSleep, DurationMs: 100},
+			{Type: ScriptActionType_ActionTypeSet, Address: "/done", Values: float32Values(1)},
+		}}
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), srr)
+		require.Nil(t, reply.Error)
+		resp := &ScriptRunResponse{}
+		require.NoError(t, resp.UnmarshalVT(reply.GetMessage()))
+		return resp.GetScheduledId()
+	}
+	list := func() []*ScheduledRun {
+		t.Helper()
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCHEDULED_RUN_LIST_REQ), &ScheduledRunListRequest{})
+		require.Nil(t, reply.Error)
+		resp := &ScheduledRunListResponse{}
+		require.NoError(t, resp.UnmarshalVT(reply.GetMessage()))
+		return resp.GetRuns()
+	}
+	cancel := func(id int32) *core.Error {
+		t.Helper()
+		return busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCHEDULED_RUN_CANCEL_REQ), &ScheduledRunCancelRequest{Id: id}).Error
+	}
+
+	fh.now = 1000
+	require.Equal(t, int32(1), run(&ScriptRunRequest{Target: "overlay", DelayMs: 500}))
+	require.Equal(t, int32(2), run(&ScriptRunRequest{Target: "lights", StartAtMs: 1500}))
+	require.Equal(t, int32(3), run(&ScriptRunRequest{Target: "mixer", StartAtMs: 1200}))
+	require.Equal(t, []*ScheduledRun{
+		{Id: 3, Target: "mixer", ScriptName: "mixer", StartAtMs: 1200},
+		{Id: 1, Target: "overlay", ScriptName: "overlay", StartAtMs: 1500},
+		{Id: 2, Target: "lights", ScriptName: "lights", StartAtMs: 1500},
+	}, list())
+	require.Nil(t, cancel(3))
+	require.Equal(t, int32(core.CommonErrorCode_NOT_FOUND), cancel(3).GetCode(), "already cancelled")
+
+	tick(t, rsc, fh, 1499)
+	require.Empty(t, fh.takeSent())
+	tick(t, rsc, fh, 1503)
+	require.ElementsMatch(t, []sentMessage{
+		{target: "overlay", address: "/go", values: float32Values(1)},
+		{target: "lights", address: "/go", values: float32Values(1)},
+	}, fh.takeSent(), "runs scheduled for the same time start on the same tick")
+	require.Empty(t, list())
+	tick(t, rsc, fh, 1599)
+	require.Empty(t, fh.takeSent())
+	tick(t, rsc, fh, 1600)
+	require.Len(t, fh.takeSent(), 2, "steps are timed from the scheduled start")
+
+	require.Equal(t, int32(0), run(&ScriptRunRequest{Target: "mixer", StartAtMs: 1000}), "a time that's passed starts now")
+	tick(t, rsc, fh, 1601)
+	require.Len(t, fh.takeSent(), 1)
+
+	require.NotZero(t, run(&ScriptRunRequest{Target: "mixer", DelayMs: 1000}))
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_PANIC_REQ), &PanicRequest{})
+	require.Nil(t, reply.Error)
+	pr := &PanicResponse{}
+	require.NoError(t, pr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, uint32(2), pr.GetRunnersCancelled(), "the running script and the scheduled run")
+	require.Empty(t, list())
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "mixer",
+		Script: &Script{Actions: []*ScriptAction{
+			{Type: ScriptActionType_ActionTypeSet, Address: "/go", Values: float32Values(1)},
+		}},
+		StartAtMs: 5000,
+		DelayMs:   100,
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, "start_at_ms and delay_ms are both set", reply.Error.GetDetail())
+}
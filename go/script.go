@@ -1,27 +1,32 @@
 package rosco
 
-import (
-	"github.com/autonomouskoi/core-tinygo"
-	"github.com/autonomouskoi/core-tinygo/svc"
-)
-
-const (
-	fadeStepIntervalMS = 1000 / 60 // ~60fps
-)
+import "slices"
 
 type fadeStep struct {
 	startTime, endTime int64
 	fromValue, toValue float64
-	address            string
+	target, address    string
+	curve              FadeCurve
+	// beats is set when the fade's duration is measured in beats, by step
+	beats  bool
+	step   *ScriptAction
+	frames *frameSender
 }
 
-func newFadeStep(step *ScriptAction) *fadeStep {
+// newFadeStep makes the fade for step, starting at start
+func newFadeStep(step *ScriptAction, target string, start int64, tempo *Tempo) *fadeStep {
 	if len(step.Values) < 2 {
 		return nil
 	}
+	durationMS, beats := timedDurationMS(step, tempo)
 	fs := &fadeStep{
-		endTime: int64(step.DurationMs),
-		address: step.GetAddress(),
+		startTime: start,
+		endTime:   start + durationMS,
+		beats:     beats,
+		step:      step,
+		target:    target,
+		address:   step.GetAddress(),
+		curve:     step.GetCurve(),
 	}
 	from, ok := step.Values[0].Value.(*OSCValue_Float32)
 	if !ok {
@@ -36,129 +41,306 @@ func newFadeStep(step *ScriptAction) *fadeStep {
 	return fs
 }
 
+// ease maps linear progress through a fade, from 0 to 1, onto the curve
+func ease(curve FadeCurve, progress float64) float64 {
+	switch curve {
+	case FadeCurve_CurveEaseIn:
+		return progress * progress
+	case FadeCurve_CurveEaseOut:
+		return 1 - (1-progress)*(1-progress)
+	case FadeCurve_CurveEaseInOut:
+		if progress < 0.5 {
+			return 2 * progress * progress
+		}
+		return 1 - 2*(1-progress)*(1-progress)
+	}
+	return progress
+}
+
+// nextFrame is when the frame after now is due, for frames every intervalMS
+// from start. Frames that were missed are skipped rather than sent late.
+func nextFrame(start, now, intervalMS int64) int64 {
+	return start + ((now-start)/intervalMS+1)*intervalMS
+}
+
+// a sendFunc sends an OSC message to a target
+type sendFunc func(target, address string, values []*OSCValue)
+
+// a masterFunc sets the level of a master
+type masterFunc func(name string, level float32)
+
 type scriptRunner struct {
 	target      string
-	nextAfter   int64
 	currentFade *fadeStep
 	steps       []*ScriptAction
+	send        sendFunc
+	setMaster   masterFunc
+	// script is what's running, nil for runners started without one
+	script *Script
+	// started is set when the runner first runs, at startedAt. due is when
+	// the next step or frame should run. Steps are scheduled from when the
+	// steps before them were due rather than when they ran, so late ticks
+	// don't push back the rest of the script.
+	started   bool
+	startedAt int64
+	due       int64
+	// lateMS is how late the last step or frame ran and maxLateMS the latest
+	// any has run
+	lateMS, maxLateMS int64
+	// tempo is what beats are measured against. Runners with a fixedTempo
+	// ignore changes to the global tempo.
+	tempo      *Tempo
+	fixedTempo bool
+	// sleeping is the step being slept on while its duration is measured in
+	// beats
+	sleeping *ScriptAction
+	// tracks are run instead of steps
+	tracks      []*KeyframeTrack
+	trackStates []*trackState
+	// outputs are the targets' output settings and fps the script's frame
+	// rate, for fades and tracks
+	outputs map[string]*TargetOutput
+	fps     float32
 }
 
-func newScriptRunner(target string, actions []*ScriptAction) *scriptRunner {
+func newScriptRunner(target string, actions []*ScriptAction, send sendFunc) *scriptRunner {
 	return &scriptRunner{
 		target: target,
 		steps:  actions,
+		send:   send,
 	}
 }
 
+// next runs every step and frame that's due at now, returning true when the
+// script is done
 func (sr *scriptRunner) next(now int64) bool {
-	if sr.currentFade == nil && len(sr.steps) == 0 {
-		return true
+	if !sr.started {
+		sr.started, sr.startedAt, sr.due = true, now, now
 	}
-	if sr.nextAfter >= now {
+	if sr.due > now {
 		return false
 	}
-	if sr.currentFade != nil {
-		sr.doFade(now)
-		return false
+	sr.lateMS = now - sr.due
+	sr.maxLateMS = max(sr.maxLateMS, sr.lateMS)
+	if len(sr.tracks) > 0 {
+		return sr.doTracks(now)
+	}
+	for sr.due <= now {
+		switch {
+		case sr.currentFade != nil:
+			sr.doFade(now)
+		case len(sr.steps) > 0:
+			sr.doStep()
+		default:
+			return true
+		}
 	}
-	sr.doStep(now)
 	return false
 }
 
+// doFade sends the current fade's value at now, or its final value if it has
+// ended, and schedules its next frame
 func (sr *scriptRunner) doFade(now int64) {
-	if sr.currentFade.startTime == 0 {
-		sr.currentFade.startTime = now
-		sr.currentFade.endTime = now + sr.currentFade.endTime
+	fade := sr.currentFade
+	if fade.endTime <= now {
+		if fade.frames.final(fade.toValue) {
+			sr.send(fade.target, fade.address, []*OSCValue{
+				{
+					Value: &OSCValue_Float32{Float32: float32(fade.toValue)},
+				},
+			})
+		}
+		sr.currentFade = nil
+		sr.due = fade.endTime
+		return
 	}
-	if sr.currentFade.endTime < now {
-		sendMessage(sr.target, sr.currentFade.address, []*OSCValue{
+	progress := float64(now-fade.startTime) / float64(fade.endTime-fade.startTime)
+	progress = ease(fade.curve, progress)
+	vDelta := fade.toValue - fade.fromValue
+	currentValue := vDelta*progress + fade.fromValue
+	if fade.frames.frame(currentValue) {
+		sr.send(fade.target, fade.address, []*OSCValue{
 			{
-				Value: &OSCValue_Float32{Float32: float32(sr.currentFade.toValue)},
+				Value: &OSCValue_Float32{Float32: float32(currentValue)},
 			},
 		})
-		sr.currentFade = nil
+	}
+	sr.due = min(nextFrame(fade.startTime, now, fade.frames.intervalMS), fade.endTime)
+}
+
+// setTempo changes the tempo of a runner that isn't using a fixed tempo,
+// stretching what remains of a sleep or fade measured in beats so it ends when
+// it would have at the new tempo and beats per bar
+func (sr *scriptRunner) setTempo(now int64, tempo *Tempo) {
+	if sr.fixedTempo {
 		return
 	}
-	startF32 := float64(sr.currentFade.startTime)
-	progress := (float64(now) - startF32) / (float64(sr.currentFade.endTime) - startF32)
-	vDelta := sr.currentFade.toValue - sr.currentFade.fromValue
-	currentValue := vDelta*progress + sr.currentFade.fromValue
-	sendMessage(sr.target, sr.currentFade.address, []*OSCValue{
-		{
-			Value: &OSCValue_Float32{Float32: float32(currentValue)},
-		},
-	})
-	sr.nextAfter = now + fadeStepIntervalMS
+	from := sr.tempo
+	sr.tempo = tempo
+	if sr.sleeping != nil && sr.due > now {
+		sr.due = now + int64(float64(sr.due-now)*tempoScale(sr.sleeping, from, tempo))
+	}
+	fade := sr.currentFade
+	if fade == nil || !fade.beats {
+		return
+	}
+	// keep the fade's progress so its value doesn't jump
+	total := float64(fade.endTime - fade.startTime)
+	progress := float64(now-fade.startTime) / total
+	total *= tempoScale(fade.step, from, tempo)
+	fade.startTime = now - int64(progress*total)
+	fade.endTime = fade.startTime + int64(total)
+	sr.due = min(sr.due, fade.endTime)
+}
+
+// actionTarget is the target an action sends to, its own or the script's
+func (sr *scriptRunner) actionTarget(action *ScriptAction) string {
+	if action.GetTarget() != "" {
+		return action.GetTarget()
+	}
+	return sr.target
 }
 
-func (sr *scriptRunner) doStep(now int64) {
-	// do the step
+// startFade makes step the current fade, starting at start
+func (sr *scriptRunner) startFade(step *ScriptAction, start int64) {
+	sr.currentFade = newFadeStep(step, sr.actionTarget(step), start, sr.tempo)
+	if sr.currentFade != nil {
+		sr.currentFade.frames = sr.newFrameSender(sr.currentFade.target, step.GetFps())
+	}
+}
+
+// doStep runs the next step at the time it was due
+func (sr *scriptRunner) doStep() {
 	step := sr.steps[0]
+	sr.steps = sr.steps[1:]
+	sr.sleeping = nil
 	switch step.Type {
 	case ScriptActionType_ActionTypeFade:
-		sr.currentFade = newFadeStep(step)
+		sr.startFade(step, sr.due)
 	case ScriptActionType_ActionTypeSet:
-		sendMessage(sr.target, step.GetAddress(), step.GetValues())
+		sr.send(sr.actionTarget(step), step.GetAddress(), step.GetValues())
 	case ScriptActionType_ActionTypeSleep:
-		sr.nextAfter = now + int64(step.DurationMs)
+		durationMS, beats := timedDurationMS(step, sr.tempo)
+		sr.due += durationMS
+		if beats {
+			sr.sleeping = step
+		}
+	case ScriptActionType_ActionTypeMaster:
+		if sr.setMaster != nil && len(step.GetValues()) > 0 {
+			sr.setMaster(step.GetMaster(), step.GetValues()[0].GetFloat32())
+		}
 	}
-	sr.steps = sr.steps[1:]
 }
 
-func sendMessage(target, address string, values []*OSCValue) {
-	svcValues := make([]*svc.OSCValue, len(values))
-	for i, v := range values {
-		sv := &svc.OSCValue{}
-		switch vv := v.Value.(type) {
-		case *OSCValue_Nil:
-			sv.Value = &svc.OSCValue_Nil{Nil: vv.Nil}
-		case *OSCValue_Int32:
-			sv.Value = &svc.OSCValue_Int32{Int32: vv.Int32}
-		case *OSCValue_Float32:
-			sv.Value = &svc.OSCValue_Float32{Float32: vv.Float32}
-		case *OSCValue_String_:
-			sv.Value = &svc.OSCValue_String_{String_: vv.String_}
-		case *OSCValue_Blob:
-			sv.Value = &svc.OSCValue_Blob{Blob: vv.Blob}
-		case *OSCValue_Int64:
-			sv.Value = &svc.OSCValue_Int64{Int64: vv.Int64}
-		case *OSCValue_True:
-			sv.Value = &svc.OSCValue_True{True: vv.True}
-		case *OSCValue_False:
-			sv.Value = &svc.OSCValue_False{False: vv.False}
-		}
-		svcValues[i] = sv
+// sendOSC sends an OSC message to target, or to each member if target is a
+// target group
+func (rsc *Rosco) sendOSC(target, address string, values []*OSCValue) {
+	rsc.sendGroupOSC(target, address, values, false)
+}
+
+// sendSafeOSC sends a safe state's OSC message like sendOSC, but the values
+// aren't scaled by masters or held back by rate limits, so a panic always
+// sends them exactly
+func (rsc *Rosco) sendSafeOSC(target, address string, values []*OSCValue) {
+	rsc.sendGroupOSC(target, address, values, true)
+}
+
+func (rsc *Rosco) sendGroupOSC(target, address string, values []*OSCValue, safe bool) {
+	group, present := rsc.cfg.GetTargetGroups()[target]
+	if !present {
+		rsc.sendTargetOSC(target, address, values, safe)
+		return
 	}
-	sr := svc.OSCSendMessageRequest{
-		TargetName: target,
-		Address:    address,
-		Values:     svcValues,
+	for _, member := range group.GetMembers() {
+		rsc.sendTargetOSC(member.GetTarget(), memberAddress(member, address), memberValues(member, values), safe)
 	}
-	msg := &core.BusMessage{
-		Type: int32(svc.MessageType_OSC_SEND_MESSAGE_REQ),
+}
+
+// sendTargetOSC sends an OSC message through the host, logging any failure.
+// Values are scaled by masters, then checked against the target's limits.
+// Safe values skip masters and rate limits.
+func (rsc *Rosco) sendTargetOSC(target, address string, values []*OSCValue, safe bool) {
+	level := float32(1)
+	if !safe {
+		level = rsc.addressLevel(target, address)
 	}
-	if core.MarshalMessage(msg, &sr); msg.Error != nil {
+	limited, err := rsc.applyLimits(target, address, scaleValues(values, level), !safe)
+	if err != nil {
+		rsc.host.LogError("rejected OSC message", "target", target, "address", address, "error", err.Error())
 		return
 	}
-	reply, err := core.WaitForReply(msg, 1000)
-	if err != nil {
-		core.LogError("sending OSC message", "target", target, "error", err.Error())
+	if err := rsc.host.SendOSC(target, address, limited); err != nil {
+		rsc.host.LogError("sending OSC message", "target", target, "error", err.Error())
 		return
 	}
-	if reply.Error != nil {
-		core.LogBusError("sending OSC message", reply.Error)
+	// what was sent is recorded at full level, so it's scaled again by
+	// masters when it's sent again. At level 0 the sent values say nothing
+	// about the requested ones, so those are kept.
+	if level != 0 {
+		values = scaleValues(limited, 1/level)
 	}
+	rsc.recordSent(target, address, values)
 }
 
 func (rsc *Rosco) triggerScriptSteps(currentTimeMillis int64) {
 	for i, sr := range rsc.runners {
+		steps := len(sr.steps)
 		if done := sr.next(currentTimeMillis); done {
 			delete(rsc.runners, i)
+			rsc.runnersChanged = true
+		} else if len(sr.steps) != steps {
+			rsc.runnersStepped = true
 		}
 	}
 }
 
-func (rsc *Rosco) runScript(target string, actions []*ScriptAction) {
-	rsc.runners[rsc.runnerCount] = newScriptRunner(target, actions)
+// runnerStatuses describes every running script, ordered by when they were
+// started
+func (rsc *Rosco) runnerStatuses() []*RunnerStatus {
+	ids := make([]int, 0, len(rsc.runners))
+	for id := range rsc.runners {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	statuses := make([]*RunnerStatus, 0, len(ids))
+	for _, id := range ids {
+		sr := rsc.runners[id]
+		statuses = append(statuses, &RunnerStatus{
+			Id:         int32(id),
+			Target:     sr.target,
+			ScriptName: sr.script.GetName(),
+			StartedMs:  sr.startedAt,
+			LateMs:     uint32(sr.lateMS),
+			MaxLateMs:  uint32(sr.maxLateMS),
+		})
+	}
+	return statuses
+}
+
+// startScript starts running script on target at the script's tempo,
+// returning the runner
+func (rsc *Rosco) startScript(target string, script *Script) *scriptRunner {
+	sr := rsc.runScript(target, script.GetActions())
+	sr.tracks = script.GetTracks()
+	sr.fps = script.GetFps()
+	sr.script = script
+	sr.tempo, sr.fixedTempo = rsc.scriptTempo(script)
+	return sr
+}
+
+// runScript starts running actions on target, returning the runner. Beats are
+// measured against the global tempo.
+func (rsc *Rosco) runScript(target string, actions []*ScriptAction) *scriptRunner {
+	sr := newScriptRunner(target, actions, rsc.sendOSC)
+	sr.tempo = rsc.tempo
+	sr.outputs = rsc.cfg.GetOutputs()
+	sr.setMaster = func(name string, level float32) {
+		if _, err := rsc.setMasterLevel(name, level); err != nil {
+			rsc.host.LogError("setting master from script", "error", err.Error())
+		}
+	}
+	rsc.runners[rsc.runnerCount] = sr
 	rsc.runnerCount++
+	rsc.runnersChanged = true
+	return sr
 }
@@ -0,0 +1,140 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEase(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		curve FadeCurve
+		half  float64
+	}{
+		{curve: FadeCurve_CurveLinear, half: 0.5},
+		{curve: FadeCurve_CurveEaseIn, half: 0.25},
+		{curve: FadeCurve_CurveEaseOut, half: 0.75},
+		{curve: FadeCurve_CurveEaseInOut, half: 0.5},
+	} {
+		t.Run(tc.curve.String(), func(t *testing.T) {
+			require.Equal(t, 0.0, ease(tc.curve, 0))
+			require.Equal(t, tc.half, ease(tc.curve, 0.5))
+			require.Equal(t, 1.0, ease(tc.curve, 1))
+		})
+	}
+}
+
+func TestFade(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	rsc.runScript("mixer", []*ScriptAction{
+		{
+			Type:       ScriptActionType_ActionTypeFade,
+			Address:    "/ch/01/fader",
+			Values:     float32Values(0, 1),
+			DurationMs: 100,
+		},
+	})
+
+	for now := int64(1000); now < 1200; now++ {
+		tick(t, rsc, fh, now)
+	}
+	sent := fh.takeSent()
+	require.Greater(t, len(sent), 2)
+	require.Equal(t, float32Values(0), sent[0].values)
+	require.Equal(t, float32Values(1), sent[len(sent)-1].values)
+	var last float32
+	for _, msg := range sent {
+		require.Equal(t, "mixer", msg.target)
+		require.Equal(t, "/ch/01/fader", msg.address)
+		v := msg.values[0].GetFloat32()
+		require.GreaterOrEqual(t, v, last)
+		last = v
+	}
+	require.Empty(t, rsc.runners, "runner should finish")
+}
+
+func TestSleep(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	rsc.runScript("mixer", []*ScriptAction{
+		{
+			Type:    ScriptActionType_ActionTypeSet,
+			Address: "/a",
+			Values:  float32Values(1),
+		},
+		{
+			Type:       ScriptActionType_ActionTypeSleep,
+			DurationMs: 500,
+		},
+		{
+			Type:    ScriptActionType_ActionTypeSet,
+			Address: "/b",
+			Values:  float32Values(2),
+		},
+	})
+
+	tick(t, rsc, fh, 1000)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/a", values: float32Values(1)}}, fh.takeSent())
+
+	// the sleep starts at 1000 and runs until 1500
+	for now := int64(1001); now < 1500; now++ {
+		tick(t, rsc, fh, now)
+	}
+	require.Empty(t, fh.takeSent())
+
+	tick(t, rsc, fh, 1500)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/b", values: float32Values(2)}}, fh.takeSent())
+	require.Empty(t, rsc.runners, "runner should finish")
+}
+
+func TestNoDrift(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	set := func(address string) *ScriptAction {
+		return &ScriptAction{Type: ScriptActionType_ActionTypeSet, Address: address, Values: float32Values(1)}
+	}
+	sleep := &ScriptAction{Type: ScriptActionType_ActionTypeSleep, DurationMs: 500}
+	rsc.runScript("mixer", []*ScriptAction{set("/a"), sleep, set("/b"), sleep, set("/c")})
+
+	tick(t, rsc, fh, 1000)
+	require.Len(t, fh.takeSent(), 1)
+	tick(t, rsc, fh, 1650)
+	require.Len(t, fh.takeSent(), 1, "/b is sent late")
+	tick(t, rsc, fh, 1999)
+	require.Empty(t, fh.takeSent())
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_RUNNER_LIST_REQ), &RunnerListRequest{})
+	require.Nil(t, reply.Error)
+	rlr := &RunnerListResponse{}
+	require.NoError(t, rlr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, []*RunnerStatus{
+		{Target: "mixer", StartedMs: 1000, LateMs: 150, MaxLateMs: 150},
+	}, rlr.GetRunners())
+	tick(t, rsc, fh, 2000)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/c", values: float32Values(1)}}, fh.takeSent(),
+		"the second sleep is measured from when /b was due, not when it was sent",
+	)
+}
+
+func TestInlineScriptsValidated(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	script := &Script{Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeFade, Address: "/fader", Values: float32Values(0), DurationMs: 1000},
+	}}
+	for _, tc := range []struct {
+		msgType MessageTypeRequest
+		req     core.Marshaller
+	}{
+		{MessageTypeRequest_SCRIPT_RUN_REQ, &ScriptRunRequest{Target: "mixer", Script: script}},
+		{MessageTypeRequest_SCRIPT_SIMULATE_REQ, &ScriptSimulateRequest{Target: "mixer", Script: script}},
+	} {
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(tc.msgType), tc.req)
+		require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+		require.Equal(t, "script 0 action 0: fade needs 2 values, has 1", reply.Error.GetDetail())
+	}
+	require.Empty(t, rsc.runners)
+	require.Empty(t, fh.takeSent())
+}
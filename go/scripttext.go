@@ -0,0 +1,708 @@
+package rosco
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var fadeCurveNames = map[FadeCurve]string{
+	FadeCurve_CurveLinear:    "linear",
+	FadeCurve_CurveEaseIn:    "ease-in",
+	FadeCurve_CurveEaseOut:   "ease-out",
+	FadeCurve_CurveEaseInOut: "ease-in-out",
+}
+
+var restartPolicyNames = map[RestartPolicy]string{
+	RestartPolicy_RestartAbort:  "abort",
+	RestartPolicy_RestartResume: "resume",
+}
+
+type textToken struct {
+	text   string
+	column int
+	quoted bool
+}
+
+// tokenizeLine splits a line into whitespace separated tokens, stopping at a
+// comment. Quoted tokens are unquoted.
+func tokenizeLine(line string) ([]textToken, *ScriptTextError) {
+	var tokens []textToken
+	i := 0
+	for i < len(line) {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+		case c == '#':
+			return tokens, nil
+		case c == '"':
+			end := i + 1
+			for ; end < len(line) && line[end] != '"'; end++ {
+				if line[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(line) {
+				return nil, &ScriptTextError{Column: int32(i + 1), Message: "unterminated string"}
+			}
+			s, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, &ScriptTextError{Column: int32(i + 1), Message: "invalid string"}
+			}
+			tokens = append(tokens, textToken{text: s, column: i + 1, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+				end++
+			}
+			tokens = append(tokens, textToken{text: line[i:end], column: i + 1})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// parseScriptText compiles script text into a Script. Each line holds one
+// action, a name, or nothing:
+//
+//	# comments start with #
+//	name "Intro"
+//	set /ch/01/mix/on 1
+//	fade /ch/01/mix/fader 0.0 -> 0.75 in 2s ease-out
+//	sleep 500ms
+//	master "grand" 0.5
+//	set /dimmer 0.5 on "lights"
+//	fade /main/fader 0.0 -> 1.0 in 1s on role "audio"
+//	bpm 128
+//	sleep 2beats
+//	fade /strobe/rate 0.0 -> 1.0 in 1bar
+//	track /intro/fader on "lights"
+//	key 0s 0.0 ease-in
+//	key 30s 1.0
+//	fps 30
+//	fade /slow/fader 0.0 -> 1.0 in 1m 10fps
+//	track /slow/pan 5fps
+//	restart resume
+//
+// on TARGET sends a set or fade to that target, and on role ROLE to the
+// target given for the role when the script runs. bpm gives the script its
+// own tempo for beats and bars. track starts a keyframe track, and each key
+// after it adds a keyframe timed from the script's start; a script has
+// either actions or tracks. fps sets how often fades and tracks send, and
+// restart whether runs resume after the plugin restarts.
+//
+// Values are nil, true, false, "strings", int32s, int64s ending in L,
+// float32s with a decimal point or exponent, and blob:HEX. Durations are
+// whole milliseconds in time.ParseDuration's format, or beats or bars.
+// Each line with a problem produces an error; if there are any errors the
+// script is nil.
+func parseScriptText(text string) (*Script, []*ScriptTextError) {
+	script := &Script{}
+	var textErrs []*ScriptTextError
+	haveName, haveBPM, haveFPS, haveRestart := false, false, false, false
+	var track *KeyframeTrack
+	for i, line := range strings.Split(text, "\n") {
+		lineNum := int32(i + 1)
+		tokens, textErr := tokenizeLine(strings.TrimSuffix(line, "\r"))
+		if textErr != nil {
+			textErr.Line = lineNum
+			textErrs = append(textErrs, textErr)
+			continue
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		if tokens[0].text == "name" && !tokens[0].quoted {
+			switch {
+			case haveName:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "name is already set"}
+			case len(tokens) != 2 || !tokens[1].quoted:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: `expected name "script name"`}
+			default:
+				script.Name = tokens[1].text
+				haveName = true
+			}
+		} else if tokens[0].text == "bpm" && !tokens[0].quoted {
+			switch {
+			case haveBPM:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "bpm is already set"}
+			case len(tokens) != 2 || tokens[1].quoted:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "expected bpm BPM"}
+			default:
+				bpm, err := strconv.ParseFloat(tokens[1].text, 32)
+				if err == nil {
+					err = validateBPM(float32(bpm))
+				}
+				if err != nil {
+					textErr = &ScriptTextError{Column: int32(tokens[1].column), Message: fmt.Sprintf("invalid bpm %q", tokens[1].text)}
+					break
+				}
+				script.Bpm = float32(bpm)
+				haveBPM = true
+			}
+		} else if tokens[0].text == "fps" && !tokens[0].quoted {
+			switch {
+			case haveFPS:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "fps is already set"}
+			case len(tokens) != 2 || tokens[1].quoted:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "expected fps FPS"}
+			default:
+				fps, err := strconv.ParseFloat(tokens[1].text, 32)
+				if err == nil {
+					err = validateFPS(float32(fps))
+				}
+				if err != nil {
+					textErr = &ScriptTextError{Column: int32(tokens[1].column), Message: fmt.Sprintf("invalid fps %q", tokens[1].text)}
+					break
+				}
+				script.Fps = float32(fps)
+				haveFPS = true
+			}
+		} else if tokens[0].text == "restart" && !tokens[0].quoted {
+			switch {
+			case haveRestart:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "restart is already set"}
+			case len(tokens) != 2 || tokens[1].quoted:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "expected restart abort or restart resume"}
+			default:
+				policy, ok := parseRestartPolicy(tokens[1].text)
+				if !ok {
+					textErr = &ScriptTextError{Column: int32(tokens[1].column), Message: fmt.Sprintf("unknown restart policy %q", tokens[1].text)}
+					break
+				}
+				script.Restart = policy
+				haveRestart = true
+			}
+		} else if tokens[0].text == "track" && !tokens[0].quoted {
+			track, textErr = parseTrack(tokens)
+			switch {
+			case textErr != nil:
+			case len(script.GetActions()) > 0:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "script can't have both actions and tracks"}
+			default:
+				script.Tracks = append(script.Tracks, track)
+			}
+		} else if tokens[0].text == "key" && !tokens[0].quoted {
+			var keyframe *Keyframe
+			keyframe, textErr = parseKeyframe(tokens)
+			switch {
+			case textErr != nil:
+			case track == nil:
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "key must follow a track"}
+			default:
+				track.Keyframes = append(track.Keyframes, keyframe)
+			}
+		} else {
+			var action *ScriptAction
+			action, textErr = parseAction(tokens)
+			if action != nil && len(script.GetTracks()) > 0 {
+				textErr = &ScriptTextError{Column: int32(tokens[0].column), Message: "script can't have both actions and tracks"}
+			} else if action != nil {
+				script.Actions = append(script.Actions, action)
+			}
+		}
+		if textErr != nil {
+			textErr.Line = lineNum
+			textErrs = append(textErrs, textErr)
+		}
+	}
+	if len(textErrs) > 0 {
+		return nil, textErrs
+	}
+	return script, nil
+}
+
+// onClause is a trailing on TARGET or on role ROLE
+type onClause struct {
+	on           *textToken
+	target, role string
+}
+
+// splitOnClause removes any trailing on clause from tokens
+func splitOnClause(tokens []textToken) ([]textToken, onClause, *ScriptTextError) {
+	var oc onClause
+	for i := 1; i < len(tokens); i++ {
+		if tokens[i].text != "on" || tokens[i].quoted {
+			continue
+		}
+		oc.on = &tokens[i]
+		clause := tokens[i+1:]
+		switch {
+		case len(clause) == 1:
+			oc.target = clause[0].text
+		case len(clause) == 2 && clause[0].text == "role" && !clause[0].quoted:
+			oc.role = clause[1].text
+		default:
+			return nil, oc, &ScriptTextError{Column: int32(oc.on.column), Message: "expected on TARGET or on role ROLE"}
+		}
+		return tokens[:i], oc, nil
+	}
+	return tokens, oc, nil
+}
+
+// parseTrack parses a track line: track ADDRESS [FPS], with an optional on
+// clause
+func parseTrack(tokens []textToken) (*KeyframeTrack, *ScriptTextError) {
+	tokens, oc, textErr := splitOnClause(tokens)
+	if textErr != nil {
+		return nil, textErr
+	}
+	if len(tokens) != 2 && len(tokens) != 3 {
+		return nil, &ScriptTextError{Column: int32(tokens[0].column), Message: "expected track ADDRESS [FPS]"}
+	}
+	if err := validateOSCAddress(tokens[1].text); err != nil {
+		return nil, &ScriptTextError{Column: int32(tokens[1].column), Message: err.Error()}
+	}
+	track := &KeyframeTrack{
+		Address: tokens[1].text,
+		Target:  oc.target,
+		Role:    oc.role,
+	}
+	if len(tokens) == 3 {
+		fps, ok := parseFPS(tokens[2])
+		if !ok {
+			return nil, &ScriptTextError{Column: int32(tokens[2].column), Message: fmt.Sprintf("invalid fps %q", tokens[2].text)}
+		}
+		track.Fps = fps
+	}
+	return track, nil
+}
+
+// parseFPS parses a frame rate written as a number followed by fps
+func parseFPS(tok textToken) (float32, bool) {
+	if tok.quoted || !strings.HasSuffix(tok.text, "fps") {
+		return 0, false
+	}
+	fps, err := strconv.ParseFloat(strings.TrimSuffix(tok.text, "fps"), 32)
+	if err != nil || validateFPS(float32(fps)) != nil {
+		return 0, false
+	}
+	return float32(fps), true
+}
+
+// parseKeyframe parses a key line: key TIME VALUE [CURVE]
+func parseKeyframe(tokens []textToken) (*Keyframe, *ScriptTextError) {
+	if len(tokens) != 3 && len(tokens) != 4 {
+		return nil, &ScriptTextError{Column: int32(tokens[0].column), Message: "expected key TIME VALUE [CURVE]"}
+	}
+	timeMS, err := parseDurationMS(tokens[1].text)
+	if err != nil {
+		return nil, &ScriptTextError{Column: int32(tokens[1].column), Message: err.Error()}
+	}
+	value, err := strconv.ParseFloat(tokens[2].text, 32)
+	if err != nil || tokens[2].quoted {
+		return nil, &ScriptTextError{Column: int32(tokens[2].column), Message: fmt.Sprintf("invalid key value %q", tokens[2].text)}
+	}
+	keyframe := &Keyframe{TimeMs: timeMS, Value: float32(value)}
+	if len(tokens) == 4 {
+		curve, ok := parseFadeCurve(tokens[3].text)
+		if !ok {
+			return nil, &ScriptTextError{Column: int32(tokens[3].column), Message: fmt.Sprintf("unknown curve %q", tokens[3].text)}
+		}
+		keyframe.Curve = curve
+	}
+	return keyframe, nil
+}
+
+// parseAction parses the tokens from one line into an action, including any
+// trailing target or role
+func parseAction(tokens []textToken) (*ScriptAction, *ScriptTextError) {
+	tokens, oc, textErr := splitOnClause(tokens)
+	if textErr != nil {
+		return nil, textErr
+	}
+	action, textErr := parseCommand(tokens)
+	if textErr != nil || oc.on == nil {
+		return action, textErr
+	}
+	if action.GetType() != ScriptActionType_ActionTypeSet && action.GetType() != ScriptActionType_ActionTypeFade {
+		return nil, &ScriptTextError{Column: int32(oc.on.column), Message: "only set and fade can have a target or role"}
+	}
+	action.Target = oc.target
+	action.Role = oc.role
+	return action, nil
+}
+
+// parseCommand parses the tokens for an action without a target or role
+func parseCommand(tokens []textToken) (*ScriptAction, *ScriptTextError) {
+	tokenErr := func(tok textToken, format string, args ...any) *ScriptTextError {
+		return &ScriptTextError{
+			Column:  int32(tok.column),
+			Message: fmt.Sprintf(format, args...),
+		}
+	}
+	parseAddress := func() (string, *ScriptTextError) {
+		if len(tokens) < 2 {
+			return "", tokenErr(tokens[0], "expected an address after %s", tokens[0].text)
+		}
+		if err := validateOSCAddress(tokens[1].text); err != nil {
+			return "", tokenErr(tokens[1], "%s", err.Error())
+		}
+		return tokens[1].text, nil
+	}
+
+	cmd := tokens[0]
+	if cmd.quoted {
+		return nil, tokenErr(cmd, "expected a command, got a string")
+	}
+	switch cmd.text {
+	case "set":
+		address, textErr := parseAddress()
+		if textErr != nil {
+			return nil, textErr
+		}
+		action := &ScriptAction{
+			Type:    ScriptActionType_ActionTypeSet,
+			Address: address,
+		}
+		for _, tok := range tokens[2:] {
+			v, err := parseValue(tok)
+			if err != nil {
+				return nil, tokenErr(tok, "%s", err.Error())
+			}
+			action.Values = append(action.Values, v)
+		}
+		return action, nil
+
+	case "fade":
+		address, textErr := parseAddress()
+		if textErr != nil {
+			return nil, textErr
+		}
+		if len(tokens) < 7 || len(tokens) > 9 ||
+			tokens[3].text != "->" || tokens[5].text != "in" {
+			return nil, tokenErr(cmd, "expected fade ADDRESS FROM -> TO in DURATION [CURVE] [FPS]")
+		}
+		action := &ScriptAction{
+			Type:    ScriptActionType_ActionTypeFade,
+			Address: address,
+		}
+		for _, tok := range []textToken{tokens[2], tokens[4]} {
+			f, err := strconv.ParseFloat(tok.text, 32)
+			if err != nil || tok.quoted {
+				return nil, tokenErr(tok, "invalid fade value %q", tok.text)
+			}
+			action.Values = append(action.Values, &OSCValue{
+				Value: &OSCValue_Float32{Float32: float32(f)},
+			})
+		}
+		if err := parseTiming(tokens[6].text, action); err != nil {
+			return nil, tokenErr(tokens[6], "%s", err.Error())
+		}
+		extra := tokens[7:]
+		if len(extra) > 0 {
+			if fps, ok := parseFPS(extra[len(extra)-1]); ok {
+				action.Fps = fps
+				extra = extra[:len(extra)-1]
+			}
+		}
+		switch {
+		case len(extra) > 1:
+			return nil, tokenErr(extra[1], "invalid fps %q", extra[1].text)
+		case len(extra) == 1:
+			curve, ok := parseFadeCurve(extra[0].text)
+			if !ok {
+				return nil, tokenErr(extra[0], "unknown curve %q", extra[0].text)
+			}
+			action.Curve = curve
+		}
+		return action, nil
+
+	case "sleep":
+		if len(tokens) != 2 {
+			return nil, tokenErr(cmd, "expected sleep DURATION")
+		}
+		action := &ScriptAction{
+			Type: ScriptActionType_ActionTypeSleep,
+		}
+		if err := parseTiming(tokens[1].text, action); err != nil {
+			return nil, tokenErr(tokens[1], "%s", err.Error())
+		}
+		return action, nil
+
+	case "master":
+		if len(tokens) != 3 {
+			return nil, tokenErr(cmd, "expected master NAME LEVEL")
+		}
+		level, err := strconv.ParseFloat(tokens[2].text, 32)
+		if err != nil || tokens[2].quoted {
+			return nil, tokenErr(tokens[2], "invalid master level %q", tokens[2].text)
+		}
+		return &ScriptAction{
+			Type:   ScriptActionType_ActionTypeMaster,
+			Master: tokens[1].text,
+			Values: []*OSCValue{{Value: &OSCValue_Float32{Float32: float32(level)}}},
+		}, nil
+	}
+	return nil, tokenErr(cmd, "unknown command %q", cmd.text)
+}
+
+func parseFadeCurve(s string) (FadeCurve, bool) {
+	for curve, name := range fadeCurveNames {
+		if name == s {
+			return curve, true
+		}
+	}
+	return 0, false
+}
+
+func parseRestartPolicy(s string) (RestartPolicy, bool) {
+	for policy, name := range restartPolicyNames {
+		if name == s {
+			return policy, true
+		}
+	}
+	return 0, false
+}
+
+// parseTiming sets an action's duration, or its beats or bars if s is a
+// number followed by beats or bars
+func parseTiming(s string, action *ScriptAction) error {
+	for _, unit := range []struct {
+		suffix string
+		count  *float32
+	}{
+		{"beats", &action.Beats},
+		{"beat", &action.Beats},
+		{"bars", &action.Bars},
+		{"bar", &action.Bars},
+	} {
+		if !strings.HasSuffix(s, unit.suffix) {
+			continue
+		}
+		n, err := strconv.ParseFloat(strings.TrimSuffix(s, unit.suffix), 32)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid %s %q", unit.suffix, s)
+		}
+		*unit.count = float32(n)
+		return nil
+	}
+	var err error
+	action.DurationMs, err = parseDurationMS(s)
+	return err
+}
+
+func parseDurationMS(s string) (uint32, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if d < 0 {
+		return 0, errors.New("duration is negative")
+	}
+	ms := d.Milliseconds()
+	if ms > math.MaxUint32 {
+		return 0, errors.New("duration is too long")
+	}
+	return uint32(ms), nil
+}
+
+func parseValue(tok textToken) (*OSCValue, error) {
+	if tok.quoted {
+		return &OSCValue{Value: &OSCValue_String_{String_: tok.text}}, nil
+	}
+	s := tok.text
+	switch {
+	case s == "nil":
+		return &OSCValue{Value: &OSCValue_Nil{}}, nil
+	case s == "true":
+		return &OSCValue{Value: &OSCValue_True{True: true}}, nil
+	case s == "false":
+		return &OSCValue{Value: &OSCValue_False{False: true}}, nil
+	case strings.HasPrefix(s, "blob:"):
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "blob:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid blob %q", s)
+		}
+		return &OSCValue{Value: &OSCValue_Blob{Blob: b}}, nil
+	case strings.HasSuffix(s, "L"):
+		i, err := strconv.ParseInt(strings.TrimSuffix(s, "L"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int64 %q", s)
+		}
+		return &OSCValue{Value: &OSCValue_Int64{Int64: i}}, nil
+	case strings.ContainsAny(s, ".eEnN"):
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float32 %q", s)
+		}
+		return &OSCValue{Value: &OSCValue_Float32{Float32: float32(f)}}, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", s)
+	}
+	return &OSCValue{Value: &OSCValue_Int32{Int32: int32(i)}}, nil
+}
+
+// renderScriptText writes a script in its text form. An error is returned for
+// actions that can't be expressed as text, like fades without two float32
+// values.
+func renderScriptText(script *Script) (string, error) {
+	var b strings.Builder
+	if script.GetName() != "" {
+		fmt.Fprintf(&b, "name %s\n", strconv.Quote(script.GetName()))
+	}
+	if script.GetBpm() != 0 {
+		fmt.Fprintf(&b, "bpm %s\n", strconv.FormatFloat(float64(script.GetBpm()), 'g', -1, 32))
+	}
+	if script.GetFps() != 0 {
+		fmt.Fprintf(&b, "fps %s\n", strconv.FormatFloat(float64(script.GetFps()), 'g', -1, 32))
+	}
+	if script.GetRestart() != RestartPolicy_RestartAbort {
+		name, ok := restartPolicyNames[script.GetRestart()]
+		if !ok {
+			return "", fmt.Errorf("unknown restart policy %d", script.GetRestart())
+		}
+		fmt.Fprintf(&b, "restart %s\n", name)
+	}
+	for i, action := range script.GetActions() {
+		timing, err := formatTiming(action)
+		if err != nil {
+			return "", fmt.Errorf("action %d: %w", i, err)
+		}
+		switch action.GetType() {
+		case ScriptActionType_ActionTypeSet:
+			b.WriteString("set " + action.GetAddress())
+			for _, v := range action.GetValues() {
+				b.WriteString(" " + formatValue(v))
+			}
+		case ScriptActionType_ActionTypeFade:
+			values := action.GetValues()
+			if len(values) < 2 {
+				return "", fmt.Errorf("action %d: fade needs 2 values", i)
+			}
+			from, fromOK := values[0].GetValue().(*OSCValue_Float32)
+			to, toOK := values[1].GetValue().(*OSCValue_Float32)
+			if !fromOK || !toOK {
+				return "", fmt.Errorf("action %d: fade values must be float32", i)
+			}
+			fmt.Fprintf(&b, "fade %s %s -> %s in %s",
+				action.GetAddress(),
+				formatFloat(from.Float32), formatFloat(to.Float32),
+				timing,
+			)
+			if action.GetCurve() != FadeCurve_CurveLinear {
+				name, ok := fadeCurveNames[action.GetCurve()]
+				if !ok {
+					return "", fmt.Errorf("action %d: unknown curve %d", i, action.GetCurve())
+				}
+				b.WriteString(" " + name)
+			}
+			if action.GetFps() != 0 {
+				b.WriteString(" " + formatFPS(action.GetFps()))
+			}
+		case ScriptActionType_ActionTypeSleep:
+			b.WriteString("sleep " + timing)
+		case ScriptActionType_ActionTypeMaster:
+			values := action.GetValues()
+			if len(values) != 1 {
+				return "", fmt.Errorf("action %d: master needs 1 value", i)
+			}
+			level, ok := values[0].GetValue().(*OSCValue_Float32)
+			if !ok {
+				return "", fmt.Errorf("action %d: master level must be float32", i)
+			}
+			fmt.Fprintf(&b, "master %s %s", strconv.Quote(action.GetMaster()), formatFloat(level.Float32))
+		default:
+			return "", fmt.Errorf("action %d: unknown action type %d", i, action.GetType())
+		}
+		b.WriteString(formatOnClause(action.GetTarget(), action.GetRole()) + "\n")
+	}
+	for i, track := range script.GetTracks() {
+		b.WriteString("track " + track.GetAddress())
+		if track.GetFps() != 0 {
+			b.WriteString(" " + formatFPS(track.GetFps()))
+		}
+		b.WriteString(formatOnClause(track.GetTarget(), track.GetRole()) + "\n")
+		for _, keyframe := range track.GetKeyframes() {
+			fmt.Fprintf(&b, "key %s %s", formatDurationMS(keyframe.GetTimeMs()), formatFloat(keyframe.GetValue()))
+			if keyframe.GetCurve() != FadeCurve_CurveLinear {
+				name, ok := fadeCurveNames[keyframe.GetCurve()]
+				if !ok {
+					return "", fmt.Errorf("track %d: unknown curve %d", i, keyframe.GetCurve())
+				}
+				b.WriteString(" " + name)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String(), nil
+}
+
+func formatFPS(fps float32) string {
+	return strconv.FormatFloat(float64(fps), 'g', -1, 32) + "fps"
+}
+
+// formatOnClause writes the on clause for a target or role, if there is one
+func formatOnClause(target, role string) string {
+	switch {
+	case target != "":
+		return " on " + strconv.Quote(target)
+	case role != "":
+		return " on role " + strconv.Quote(role)
+	}
+	return ""
+}
+
+// formatTiming writes a sleep or fade's duration, beats, or bars
+func formatTiming(action *ScriptAction) (string, error) {
+	count := func(n float32, unit string) string {
+		s := strconv.FormatFloat(float64(n), 'g', -1, 32) + unit
+		if n != 1 {
+			s += "s"
+		}
+		return s
+	}
+	switch {
+	case action.GetBeats() != 0 && action.GetBars() != 0:
+		return "", errors.New("can't have both beats and bars")
+	case action.GetBeats() != 0:
+		return count(action.GetBeats(), "beat"), nil
+	case action.GetBars() != 0:
+		return count(action.GetBars(), "bar"), nil
+	}
+	return formatDurationMS(action.GetDurationMs()), nil
+}
+
+func formatDurationMS(ms uint32) string {
+	if ms > 0 && ms%1000 == 0 {
+		return strconv.FormatUint(uint64(ms/1000), 10) + "s"
+	}
+	return strconv.FormatUint(uint64(ms), 10) + "ms"
+}
+
+// formatFloat writes f so that it reads back as a float32
+func formatFloat(f float32) string {
+	s := strconv.FormatFloat(float64(f), 'g', -1, 32)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
+
+func formatValue(v *OSCValue) string {
+	switch vv := v.GetValue().(type) {
+	case *OSCValue_Int32:
+		return strconv.FormatInt(int64(vv.Int32), 10)
+	case *OSCValue_Float32:
+		return formatFloat(vv.Float32)
+	case *OSCValue_String_:
+		return strconv.Quote(vv.String_)
+	case *OSCValue_Blob:
+		return "blob:" + hex.EncodeToString(vv.Blob)
+	case *OSCValue_Int64:
+		return strconv.FormatInt(vv.Int64, 10) + "L"
+	case *OSCValue_True:
+		return "true"
+	case *OSCValue_False:
+		return "false"
+	}
+	return "nil"
+}
@@ -0,0 +1,92 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScriptTextErrors(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name string
+		text string
+		errs []*ScriptTextError
+	}{
+		{
+			name: "unterminated string",
+			text: "set /a 1\nset /b \"open\n",
+			errs: []*ScriptTextError{{Line: 2, Column: 8, Message: "unterminated string"}},
+		},
+		{
+			name: "unknown command",
+			text: "# intro\n  wait 1s\n",
+			errs: []*ScriptTextError{{Line: 2, Column: 3, Message: `unknown command "wait"`}},
+		},
+		{
+			name: "quoted command",
+			text: "\"set\" /a 1\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 1, Message: "expected a command, got a string"}},
+		},
+		{
+			name: "name twice",
+			text: "name \"a\"\nname \"b\"\n",
+			errs: []*ScriptTextError{{Line: 2, Column: 1, Message: "name is already set"}},
+		},
+		{
+			name: "unquoted name",
+			text: "name intro\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 1, Message: `expected name "script name"`}},
+		},
+		{
+			name: "bad value",
+			text: "set /a 1 2x\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 10, Message: `invalid value "2x"`}},
+		},
+		{
+			name: "missing address",
+			text: "set\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 1, Message: "expected an address after set"}},
+		},
+		{
+			name: "fade shape",
+			text: "fade /a 0.0 to 1.0 in 1s\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 1, Message: "expected fade ADDRESS FROM -> TO in DURATION [CURVE] [FPS]"}},
+		},
+		{
+			name: "fade value",
+			text: "fade /a 0.0 -> high in 1s\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 16, Message: `invalid fade value "high"`}},
+		},
+		{
+			name: "fade curve",
+			text: "fade /a 0.0 -> 1.0 in 1s wobble\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 26, Message: `unknown curve "wobble"`}},
+		},
+		{
+			name: "duration",
+			text: "sleep soon\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 7, Message: `invalid duration "soon"`}},
+		},
+		{
+			name: "master level",
+			text: "master \"grand\" loud\n",
+			errs: []*ScriptTextError{{Line: 1, Column: 16, Message: `invalid master level "loud"`}},
+		},
+		{
+			name: "every bad line is reported",
+			text: "sleep 1s\r\nsleep\r\nset /a 1\r\nfly\r\n",
+			errs: []*ScriptTextError{
+				{Line: 2, Column: 1, Message: "expected sleep DURATION"},
+				{Line: 4, Column: 1, Message: `unknown command "fly"`},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			script, textErrs := parseScriptText(tc.text)
+			require.Nil(t, script)
+			require.Equal(t, tc.errs, textErrs)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package rosco
+
+import (
+	"fmt"
+)
+
+const (
+	// simulations producing more messages than this are abandoned
+	maxSimulatedMessages = 100_000
+	// simulations taking more steps than this are abandoned, e.g. a long
+	// fade whose frames don't change the value sends nothing but still takes
+	// a step per frame
+	maxSimulatedSteps = 1_000_000
+)
+
+// simulateScript runs script at tempo with the given target outputs against
+// a virtual clock, returning every message the script would send and how long
+// it would run. Target groups, masters and limits are left out, as they depend
+// on the config and state when the script really runs.
+func simulateScript(target string, script *Script, tempo *Tempo, outputs map[string]*TargetOutput) ([]*SimulatedMessage, uint32, error) {
+	var msgs []*SimulatedMessage
+	var now int64
+	sr := newScriptRunner(target, script.GetActions(), func(target, address string, values []*OSCValue) {
+		msgs = append(msgs, &SimulatedMessage{
+			OffsetMs: uint32(now),
+			Target:   target,
+			Address:  address,
+			Values:   values,
+		})
+	})
+	sr.setMaster = func(name string, level float32) {
+		msgs = append(msgs, &SimulatedMessage{
+			OffsetMs: uint32(now),
+			Master:   name,
+			Values:   []*OSCValue{{Value: &OSCValue_Float32{Float32: level}}},
+		})
+	}
+	sr.tempo = tempo
+	sr.tracks = script.GetTracks()
+	sr.outputs = outputs
+	sr.fps = script.GetFps()
+	for steps := 1; !sr.next(now); steps++ {
+		if len(msgs) > maxSimulatedMessages {
+			return nil, 0, fmt.Errorf("script sends more than %d messages", maxSimulatedMessages)
+		}
+		if steps >= maxSimulatedSteps {
+			return nil, 0, fmt.Errorf("script takes more than %d steps", maxSimulatedSteps)
+		}
+		// skip ahead to when the runner next has something to do
+		now = sr.due
+	}
+	return msgs, uint32(now), nil
+}
@@ -0,0 +1,72 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateScript(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name       string
+		actions    []*ScriptAction
+		offsets    []uint32
+		durationMS uint32
+		err        string
+	}{
+		{
+			name: "sets and sleeps",
+			actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeSet, Address: "/a", Values: float32Values(1)},
+				{Type: ScriptActionType_ActionTypeSleep, DurationMs: 86_400_000},
+				{Type: ScriptActionType_ActionTypeSet, Address: "/b", Values: float32Values(1)},
+			},
+			offsets:    []uint32{0, 86_400_000},
+			durationMS: 86_400_000,
+		},
+		{
+			name: "too many messages",
+			actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeFade, Address: "/a", Values: float32Values(0, 1), DurationMs: 3_600_000},
+			},
+			err: "script sends more than 100000 messages",
+		},
+		{
+			name: "too many steps",
+			actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeFade, Address: "/a", Values: float32Values(1, 1), DurationMs: 86_400_000},
+			},
+			err: "script takes more than 1000000 steps",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			msgs, durationMS, err := simulateScript("mixer", &Script{Actions: tc.actions}, &Tempo{Bpm: 120}, nil)
+			if tc.err != "" {
+				require.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			var offsets []uint32
+			for _, msg := range msgs {
+				offsets = append(offsets, msg.GetOffsetMs())
+			}
+			require.Equal(t, tc.offsets, offsets)
+			require.Equal(t, tc.durationMS, durationMS)
+		})
+	}
+}
+
+func TestSimulateScriptAsSent(t *testing.T) {
+	t.Parallel()
+	msgs, _, err := simulateScript("mixers", &Script{Actions: []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSet, Address: "/a", Values: float32Values(2)},
+		{Type: ScriptActionType_ActionTypeMaster, Master: "grand", Values: float32Values(0.5)},
+	}}, &Tempo{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*SimulatedMessage{
+		{Target: "mixers", Address: "/a", Values: float32Values(2)},
+		{Master: "grand", Values: float32Values(0.5)},
+	}, msgs, "groups, masters and limits aren't applied, and master actions are reported")
+}
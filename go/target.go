@@ -0,0 +1,52 @@
+package rosco
+
+import (
+	"strings"
+)
+
+// groupTargets returns the members of the target group named target, or
+// target itself if it isn't a group
+func (rsc *Rosco) groupTargets(target string) []string {
+	group, present := rsc.cfg.GetTargetGroups()[target]
+	if !present {
+		return []string{target}
+	}
+	targets := make([]string, len(group.GetMembers()))
+	for i, member := range group.GetMembers() {
+		targets[i] = member.GetTarget()
+	}
+	return targets
+}
+
+// memberAddress rewrites address for a group member. The prefix only matches
+// whole address parts, so /light matches /light/1 but not /lighting.
+func memberAddress(member *TargetGroupMember, address string) string {
+	from := member.GetPrefixFrom()
+	if !strings.HasPrefix(address, from) {
+		return address
+	}
+	rest := address[len(from):]
+	if from != "" && rest != "" && !strings.HasSuffix(from, "/") && !strings.HasPrefix(rest, "/") {
+		return address
+	}
+	return member.GetPrefixTo() + address[len(from):]
+}
+
+// memberValues applies a group member's scale and offset to float32 values
+func memberValues(member *TargetGroupMember, values []*OSCValue) []*OSCValue {
+	scale := member.GetScale()
+	if scale == 0 {
+		scale = 1
+	}
+	if scale == 1 && member.GetOffset() == 0 {
+		return values
+	}
+	adjusted := make([]*OSCValue, len(values))
+	for i, v := range values {
+		if f, ok := v.GetValue().(*OSCValue_Float32); ok {
+			v = &OSCValue{Value: &OSCValue_Float32{Float32: f.Float32*scale + member.GetOffset()}}
+		}
+		adjusted[i] = v
+	}
+	return adjusted
+}
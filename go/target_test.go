@@ -0,0 +1,78 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetGroups(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			TargetGroups: map[string]*TargetGroup{
+				"mixers": {Members: []*TargetGroupMember{
+					{Target: "main"},
+					{Target: "backup", PrefixFrom: "/ch/", PrefixTo: "/channel/", Scale: 0.5, Offset: 0.25},
+					{Target: "recorder", PrefixTo: "/rec"},
+				}},
+			},
+		},
+	})
+	require.Nil(t, reply.Error)
+
+	rsc.runScript("mixers", []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSet, Address: "/ch/01/fader", Values: float32Values(1)},
+		{Type: ScriptActionType_ActionTypeSet, Address: "/main/on", Values: []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}},
+	})
+	tick(t, rsc, fh, 1000)
+	tick(t, rsc, fh, 1001)
+	require.Equal(t, []sentMessage{
+		{target: "main", address: "/ch/01/fader", values: float32Values(1)},
+		{target: "backup", address: "/channel/01/fader", values: float32Values(0.75)},
+		{target: "recorder", address: "/rec/ch/01/fader", values: float32Values(1)},
+		{target: "main", address: "/main/on", values: []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}},
+		{target: "backup", address: "/main/on", values: []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}},
+		{target: "recorder", address: "/rec/main/on", values: []*OSCValue{{Value: &OSCValue_Int32{Int32: 1}}}},
+	}, fh.takeSent())
+
+	scene := rsc.captureScene("both", []string{"mixers"})
+	require.Len(t, scene.GetValues(), 6, "groups capture their members")
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{
+			Revision: rsc.cfg.GetRevision(),
+			TargetGroups: map[string]*TargetGroup{
+				"a": {Members: []*TargetGroupMember{{Target: "b"}}},
+				"b": {Members: []*TargetGroupMember{{Target: "main", PrefixTo: "rec"}}},
+			},
+		},
+	})
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+	require.Equal(t, `target group "a" member 0: member "b" is a target group; `+
+		`target group "b" member 0: prefix to: address must start with /`,
+		reply.Error.GetDetail(),
+	)
+}
+
+func TestMemberAddress(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name, from, to, address, want string
+	}{
+		{name: "no prefix", to: "/rec", address: "/light/1", want: "/rec/light/1"},
+		{name: "exact", from: "/light", to: "/lamp", address: "/light", want: "/lamp"},
+		{name: "whole part", from: "/light", to: "/lamp", address: "/light/1", want: "/lamp/1"},
+		{name: "part of a part", from: "/light", to: "/lamp", address: "/lighting2/1", want: "/lighting2/1"},
+		{name: "trailing slash", from: "/ch/", to: "/channel/", address: "/ch/01", want: "/channel/01"},
+		{name: "no match", from: "/ch/", to: "/channel/", address: "/bus/01", want: "/bus/01"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			member := &TargetGroupMember{PrefixFrom: tc.from, PrefixTo: tc.to}
+			require.Equal(t, tc.want, memberAddress(member, tc.address))
+		})
+	}
+}
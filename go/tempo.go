@@ -0,0 +1,145 @@
+package rosco
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/autonomouskoi/akcore"
+)
+
+const (
+	defaultBPM         = 120
+	defaultBeatsPerBar = 4
+	minBPM             = 10
+	maxBPM             = 400
+	// taps further apart than this start a new run
+	tapResetMS = 2000
+	// tap tempo averages the intervals between this many taps
+	maxTaps = 5
+)
+
+var (
+	tempoKVKey = []byte("tempo")
+)
+
+// currentBPM is the tempo's BPM, or the default if it isn't set
+func (t *Tempo) currentBPM() float64 {
+	if t.GetBpm() <= 0 {
+		return defaultBPM
+	}
+	return float64(t.GetBpm())
+}
+
+// currentBeatsPerBar is the tempo's beats per bar, or the default if it isn't
+// set
+func (t *Tempo) currentBeatsPerBar() uint32 {
+	if t.GetBeatsPerBar() == 0 {
+		return defaultBeatsPerBar
+	}
+	return t.GetBeatsPerBar()
+}
+
+// timedDurationMS is how long a sleep or fade lasts at tempo, and whether
+// that was measured in beats
+func timedDurationMS(action *ScriptAction, tempo *Tempo) (int64, bool) {
+	beats := float64(action.GetBeats()) + float64(action.GetBars())*float64(tempo.currentBeatsPerBar())
+	if beats <= 0 {
+		return int64(action.GetDurationMs()), false
+	}
+	return int64(beats * 60_000 / tempo.currentBPM()), true
+}
+
+// tempoScale is how much longer action takes at tempo to than at tempo from
+func tempoScale(action *ScriptAction, from, to *Tempo) float64 {
+	fromMS, _ := timedDurationMS(action, from)
+	toMS, _ := timedDurationMS(action, to)
+	if fromMS <= 0 {
+		return 1
+	}
+	return float64(toMS) / float64(fromMS)
+}
+
+// validateBPM checks a BPM that's been set
+func validateBPM(bpm float32) error {
+	if bpm < minBPM || bpm > maxBPM {
+		return fmt.Errorf("bpm %v is outside %d-%d", bpm, minBPM, maxBPM)
+	}
+	return nil
+}
+
+// loadTempo retrieves the global tempo
+func (rsc *Rosco) loadTempo() error {
+	rsc.tempo = &Tempo{}
+	err := rsc.kvGetProto(tempoKVKey, rsc.tempo)
+	if err != nil && !errors.Is(err, akcore.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// scriptTempo is the tempo a script's beats are measured against, and whether
+// it's the script's own
+func (rsc *Rosco) scriptTempo(script *Script) (*Tempo, bool) {
+	if script.GetBpm() <= 0 {
+		return rsc.tempo, false
+	}
+	return &Tempo{Bpm: script.GetBpm(), BeatsPerBar: rsc.tempo.GetBeatsPerBar()}, true
+}
+
+// setTempo changes the global tempo, leaving fields that are 0 as they are.
+// Running scripts using the global tempo are stretched to match.
+func (rsc *Rosco) setTempo(bpm float32, beatsPerBar uint32) (*Tempo, error) {
+	tempo := rsc.tempo.CloneVT()
+	if bpm != 0 {
+		if err := validateBPM(bpm); err != nil {
+			return nil, err
+		}
+		tempo.Bpm = bpm
+	}
+	if beatsPerBar != 0 {
+		tempo.BeatsPerBar = beatsPerBar
+	}
+	now, err := rsc.host.CurrentTimeMillis()
+	if err != nil {
+		return nil, fmt.Errorf("getting time: %w", err)
+	}
+	for _, sr := range rsc.runners {
+		sr.setTempo(now, tempo)
+	}
+	rsc.runnersChanged = true
+	rsc.tempo = tempo
+	if err := rsc.kvSetProto(tempoKVKey, rsc.tempo); err != nil {
+		rsc.host.LogError("writing tempo", "error", err.Error())
+	}
+	return tempo, nil
+}
+
+// tapTempo records a tap, setting the BPM from the average interval between
+// the recent taps once there are at least two. It returns the number of taps
+// in the current run.
+func (rsc *Rosco) tapTempo() (uint32, error) {
+	now, err := rsc.host.CurrentTimeMillis()
+	if err != nil {
+		return 0, fmt.Errorf("getting time: %w", err)
+	}
+	if len(rsc.taps) > 0 && now-rsc.taps[len(rsc.taps)-1] > tapResetMS {
+		rsc.taps = nil
+	}
+	rsc.taps = append(rsc.taps, now)
+	if len(rsc.taps) > maxTaps {
+		rsc.taps = rsc.taps[len(rsc.taps)-maxTaps:]
+	}
+	taps := uint32(len(rsc.taps))
+	if taps < 2 {
+		return taps, nil
+	}
+	elapsed := rsc.taps[len(rsc.taps)-1] - rsc.taps[0]
+	if elapsed <= 0 {
+		return taps, nil
+	}
+	bpm := float32(60_000 * float64(taps-1) / float64(elapsed))
+	if _, err := rsc.setTempo(min(max(bpm, minBPM), maxBPM), 0); err != nil {
+		return 0, err
+	}
+	return taps, nil
+}
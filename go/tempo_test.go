@@ -0,0 +1,192 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/autonomouskoi/core-tinygo"
+	"github.com/stretchr/testify/require"
+)
+
+func tempoRequest(t *testing.T, rsc *Rosco, bpm float32) *core.BusMessage {
+	t.Helper()
+	return busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_TEMPO_SET_REQ), &TempoSetRequest{Bpm: bpm})
+}
+
+func TestTimedDuration(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		action     *ScriptAction
+		tempo      *Tempo
+		durationMS int64
+		beats      bool
+	}{
+		{&ScriptAction{DurationMs: 300}, nil, 300, false},
+		{&ScriptAction{Beats: 2}, nil, 1000, true},
+		{&ScriptAction{Beats: 2}, &Tempo{Bpm: 60}, 2000, true},
+		{&ScriptAction{Bars: 1}, &Tempo{Bpm: 60, BeatsPerBar: 3}, 3000, true},
+	} {
+		durationMS, beats := timedDurationMS(tc.action, tc.tempo)
+		require.Equal(t, tc.durationMS, durationMS)
+		require.Equal(t, tc.beats, beats)
+	}
+}
+
+func TestTempoChange(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	actions := []*ScriptAction{
+		{Type: ScriptActionType_ActionTypeSleep, Beats: 4},
+		{Type: ScriptActionType_ActionTypeSet, Address: "/go", Values: float32Values(1)},
+	}
+	rsc.runScript("mixer", actions)
+	// this script has its own tempo and isn't changed
+	reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_SCRIPT_RUN_REQ), &ScriptRunRequest{
+		Target: "lights",
+		Script: &Script{Bpm: 60, Actions: actions},
+	})
+	require.Nil(t, reply.Error)
+
+	tick(t, rsc, fh, 1000)
+	tick(t, rsc, fh, 2000)
+	reply = tempoRequest(t, rsc, 240)
+	require.Nil(t, reply.Error)
+	tick(t, rsc, fh, 2499)
+	require.Empty(t, fh.takeSent())
+	tick(t, rsc, fh, 2500)
+	require.Equal(t, []sentMessage{{target: "mixer", address: "/go", values: float32Values(1)}}, fh.takeSent(),
+		"the 1s left of the sleep becomes 500ms",
+	)
+	tick(t, rsc, fh, 4999)
+	require.Empty(t, fh.takeSent())
+	tick(t, rsc, fh, 5000)
+	require.Len(t, fh.takeSent(), 1)
+
+	// the tempo is kept across restarts
+	rsc, err := newRosco(fh)
+	require.NoError(t, err)
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_TEMPO_GET_REQ), &TempoGetRequest{})
+	require.Nil(t, reply.Error)
+	tgr := &TempoGetResponse{}
+	require.NoError(t, tgr.UnmarshalVT(reply.GetMessage()))
+	require.Equal(t, float32(240), tgr.GetTempo().GetBpm())
+
+	reply = tempoRequest(t, rsc, 1000)
+	require.Equal(t, int32(core.CommonErrorCode_BAD_REQUEST), reply.Error.GetCode())
+}
+
+func TestTempoChangeFade(t *testing.T) {
+	t.Parallel()
+	var sent [][]*OSCValue
+	sr := newScriptRunner("mixer", []*ScriptAction{{
+		Type:    ScriptActionType_ActionTypeFade,
+		Address: "/fader",
+		Values:  float32Values(0, 1),
+		Beats:   2,
+	}}, func(target, address string, values []*OSCValue) {
+		sent = append(sent, values)
+	})
+	sr.next(1000)
+	sr.next(1001)
+	sr.setTempo(1501, &Tempo{Bpm: 240})
+	sr.next(1501)
+	require.Equal(t, float32Values(0.5), sent[len(sent)-1], "progress is kept")
+	require.False(t, sr.next(1750))
+	require.True(t, sr.next(1751))
+	require.Equal(t, float32Values(1), sent[len(sent)-1], "the fade ends 250ms later")
+}
+
+func TestBeatsPerBarChange(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct {
+		name  string
+		sleep *ScriptAction
+		due   int64
+	}{
+		{
+			name:  "bars",
+			sleep: &ScriptAction{Type: ScriptActionType_ActionTypeSleep, Bars: 1},
+			due:   1500,
+		},
+		{
+			name:  "bars and beats",
+			sleep: &ScriptAction{Type: ScriptActionType_ActionTypeSleep, Bars: 1, Beats: 4},
+			due:   3250,
+		},
+		{
+			name:  "beats",
+			sleep: &ScriptAction{Type: ScriptActionType_ActionTypeSleep, Beats: 4},
+			due:   2000,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var sent int
+			sr := newScriptRunner("mixer", []*ScriptAction{
+				tc.sleep,
+				{Type: ScriptActionType_ActionTypeSet, Address: "/go", Values: float32Values(1)},
+			}, func(target, address string, values []*OSCValue) {
+				sent++
+			})
+			sr.next(0)
+			sr.setTempo(1000, &Tempo{BeatsPerBar: 2})
+			sr.next(tc.due - 1)
+			require.Zero(t, sent)
+			require.True(t, sr.next(tc.due))
+			require.Equal(t, 1, sent)
+		})
+	}
+}
+
+func TestTapTempo(t *testing.T) {
+	t.Parallel()
+	rsc, fh := newTestRosco(t)
+	tap := func(now int64) *TempoTapResponse {
+		t.Helper()
+		fh.now = now
+		reply := busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_TEMPO_TAP_REQ), &TempoTapRequest{})
+		require.Nil(t, reply.Error)
+		ttr := &TempoTapResponse{}
+		require.NoError(t, ttr.UnmarshalVT(reply.GetMessage()))
+		return ttr
+	}
+
+	require.Equal(t, uint32(1), tap(1000).GetTaps())
+	require.Equal(t, float32(0), rsc.tempo.GetBpm(), "one tap doesn't set the tempo")
+	ttr := tap(1500)
+	require.Equal(t, uint32(2), ttr.GetTaps())
+	require.Equal(t, float32(120), ttr.GetTempo().GetBpm())
+	for now := int64(1900); now <= 3500; now += 400 {
+		ttr = tap(now)
+	}
+	require.Equal(t, uint32(5), ttr.GetTaps(), "only the last 5 taps count")
+	require.Equal(t, float32(150), ttr.GetTempo().GetBpm())
+	require.Equal(t, uint32(1), tap(6000).GetTaps(), "a long gap starts over")
+
+	setTestConfig(t, rsc, map[string]*Trigger{"tap": {TapTempo: true}}, nil)
+	fh.now = 9000
+	rsc.fireTrigger("tap")
+	fh.now = 9250
+	rsc.fireTrigger("tap")
+	require.Equal(t, float32(240), rsc.tempo.GetBpm())
+}
+
+func TestScriptTextTempo(t *testing.T) {
+	t.Parallel()
+	text := "bpm 128\n" +
+		"sleep 2beats\n" +
+		"fade /strobe/rate 0.0 -> 1.0 in 1bar\n" +
+		"sleep 0.5beats\n"
+	script, textErrs := parseScriptText(text)
+	require.Empty(t, textErrs)
+	require.Equal(t, float32(128), script.GetBpm())
+	require.Equal(t, float32(1), script.GetActions()[1].GetBars())
+	rendered, err := renderScriptText(script)
+	require.NoError(t, err)
+	require.Equal(t, text, rendered)
+
+	_, textErrs = parseScriptText("bpm 1\nsleep 0beats\n")
+	require.Equal(t, []*ScriptTextError{
+		{Line: 1, Column: 5, Message: `invalid bpm "1"`},
+		{Line: 2, Column: 7, Message: `invalid beats "0beats"`},
+	}, textErrs)
+}
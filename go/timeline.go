@@ -0,0 +1,42 @@
+package rosco
+
+// scriptTimeline computes when each of a script's actions starts and how long
+// the script runs at tempo, assuming each action takes exactly as long as it
+// asks to. A script with tracks runs until its last keyframe.
+func scriptTimeline(script *Script, tempo *Tempo) *ScriptTimeline {
+	if len(script.GetTracks()) > 0 {
+		return &ScriptTimeline{DurationMs: tracksDurationMS(script.GetTracks())}
+	}
+	tl := &ScriptTimeline{
+		ActionOffsetsMs: make([]uint32, len(script.GetActions())),
+	}
+	var offset uint32
+	for i, action := range script.GetActions() {
+		tl.ActionOffsetsMs[i] = offset
+		offset += actionDurationMS(action, tempo)
+	}
+	tl.DurationMs = offset
+	return tl
+}
+
+// actionDurationMS is how long an action takes at tempo before the next one
+// starts
+func actionDurationMS(action *ScriptAction, tempo *Tempo) uint32 {
+	switch action.GetType() {
+	case ScriptActionType_ActionTypeFade, ScriptActionType_ActionTypeSleep:
+		durationMS, _ := timedDurationMS(action, tempo)
+		return uint32(durationMS)
+	}
+	return 0
+}
+
+// scriptTimelines computes the timeline of every script in cfg at the current
+// tempo
+func (rsc *Rosco) scriptTimelines(cfg *Config) map[int32]*ScriptTimeline {
+	timelines := make(map[int32]*ScriptTimeline, len(cfg.GetScripts()))
+	for id, script := range cfg.GetScripts() {
+		tempo, _ := rsc.scriptTempo(script)
+		timelines[id] = scriptTimeline(script, tempo)
+	}
+	return timelines
+}
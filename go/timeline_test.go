@@ -0,0 +1,93 @@
+package rosco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptTimeline(t *testing.T) {
+	t.Parallel()
+	set := &ScriptAction{Type: ScriptActionType_ActionTypeSet, Address: "/a", Values: float32Values(1)}
+	for _, tc := range []struct {
+		name   string
+		script *Script
+		tempo  *Tempo
+		want   *ScriptTimeline
+	}{
+		{
+			name:   "empty",
+			script: &Script{},
+			want:   &ScriptTimeline{ActionOffsetsMs: []uint32{}},
+		},
+		{
+			name: "sets take no time",
+			script: &Script{Actions: []*ScriptAction{
+				set,
+				{Type: ScriptActionType_ActionTypeSleep, DurationMs: 500},
+				set,
+				{Type: ScriptActionType_ActionTypeFade, Address: "/a", Values: float32Values(0, 1), DurationMs: 1000},
+				{Type: ScriptActionType_ActionTypeMaster, Master: "grand", Values: float32Values(1)},
+			}},
+			want: &ScriptTimeline{DurationMs: 1500, ActionOffsetsMs: []uint32{0, 0, 500, 500, 1500}},
+		},
+		{
+			name: "beats and bars at the default tempo",
+			script: &Script{Actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeSleep, Beats: 2},
+				{Type: ScriptActionType_ActionTypeSleep, Bars: 1},
+				set,
+			}},
+			tempo: &Tempo{},
+			want:  &ScriptTimeline{DurationMs: 3000, ActionOffsetsMs: []uint32{0, 1000, 3000}},
+		},
+		{
+			name: "beats and bars at a set tempo",
+			script: &Script{Actions: []*ScriptAction{
+				{Type: ScriptActionType_ActionTypeSleep, Beats: 1},
+				{Type: ScriptActionType_ActionTypeFade, Address: "/a", Values: float32Values(0, 1), Bars: 1},
+			}},
+			tempo: &Tempo{Bpm: 60, BeatsPerBar: 3},
+			want:  &ScriptTimeline{DurationMs: 4000, ActionOffsetsMs: []uint32{0, 1000}},
+		},
+		{
+			name: "tracks run until the last keyframe",
+			script: &Script{Tracks: []*KeyframeTrack{
+				{Address: "/a", Keyframes: []*Keyframe{{TimeMs: 0}, {TimeMs: 2000}}},
+				{Address: "/b", Keyframes: []*Keyframe{{TimeMs: 500}, {TimeMs: 2500}}},
+			}},
+			want: &ScriptTimeline{DurationMs: 2500},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.want, scriptTimeline(tc.script, tc.tempo))
+		})
+	}
+}
+
+func TestConfigTimelinesFollowTempo(t *testing.T) {
+	t.Parallel()
+	rsc, _ := newTestRosco(t)
+	reply := busRequest(t, rsc, BusTopic_ROSCO_COMMAND, int32(MessageTypeCommand_CONFIG_SET_REQ), &ConfigSetRequest{
+		Config: &Config{Scripts: map[int32]*Script{
+			1: {Name: "global", Actions: []*ScriptAction{{Type: ScriptActionType_ActionTypeSleep, Beats: 4}}},
+			2: {Name: "own", Bpm: 240, Actions: []*ScriptAction{{Type: ScriptActionType_ActionTypeSleep, Beats: 4}}},
+		}},
+	})
+	require.Nil(t, reply.Error)
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_TEMPO_SET_REQ), &TempoSetRequest{Bpm: 60})
+	require.Nil(t, reply.Error)
+
+	reply = busRequest(t, rsc, BusTopic_ROSCO_REQUEST, int32(MessageTypeRequest_CONFIG_GET_REQ), &ConfigGetRequest{})
+	require.Nil(t, reply.Error)
+	cgr := &ConfigGetResponse{}
+	require.NoError(t, cgr.UnmarshalVT(reply.GetMessage()))
+	durations := map[int32]uint32{}
+	for id, tl := range cgr.GetTimelines() {
+		durations[id] = tl.GetDurationMs()
+	}
+	require.Equal(t, map[int32]uint32{1: 4000, 2: 1000}, durations,
+		"scripts without their own bpm follow the global tempo",
+	)
+}
@@ -0,0 +1,597 @@
+package rosco
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/autonomouskoi/core-tinygo"
+)
+
+// characters that may not appear in an OSC address we send. These are the
+// pattern-matching characters plus the ones reserved by the OSC spec.
+const oscAddressReserved = " #*,?[]{}"
+
+// validateOSCAddress checks that address is a valid OSC address to send a
+// message to
+func validateOSCAddress(address string) error {
+	if address == "" {
+		return errors.New("address is empty")
+	}
+	if address[0] != '/' {
+		return errors.New("address must start with /")
+	}
+	for i, part := range strings.Split(address[1:], "/") {
+		if part == "" {
+			return fmt.Errorf("address part %d is empty", i+1)
+		}
+		for _, c := range part {
+			if c < 0x20 || c > 0x7e {
+				return fmt.Errorf("address contains invalid character %q", c)
+			}
+			if strings.ContainsRune(oscAddressReserved, c) {
+				return fmt.Errorf("address contains reserved character %q", c)
+			}
+		}
+	}
+	return nil
+}
+
+// validateAddressPattern checks an address that selects addresses to send to,
+// where a trailing / selects everything under an address
+func validateAddressPattern(pattern string) error {
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return validateOSCAddress(pattern)
+}
+
+// validateAction checks a single script action, returning a description of
+// each problem found
+func validateAction(action *ScriptAction) []string {
+	var problems []string
+	if action.GetTarget() != "" || action.GetRole() != "" {
+		switch {
+		case action.GetType() != ScriptActionType_ActionTypeSet && action.GetType() != ScriptActionType_ActionTypeFade:
+			problems = append(problems, "only set and fade actions have a target or role")
+		case action.GetTarget() != "" && action.GetRole() != "":
+			problems = append(problems, "action has both a target and a role")
+		}
+	}
+	if action.GetBeats() != 0 || action.GetBars() != 0 {
+		switch {
+		case action.GetType() != ScriptActionType_ActionTypeSleep && action.GetType() != ScriptActionType_ActionTypeFade:
+			problems = append(problems, "only sleep and fade actions have beats or bars")
+		case action.GetBeats() < 0 || action.GetBars() < 0:
+			problems = append(problems, "beats and bars may not be negative")
+		case action.GetDurationMs() != 0:
+			problems = append(problems, "action has both a duration and beats or bars")
+		}
+	}
+	if action.GetFps() != 0 {
+		if action.GetType() != ScriptActionType_ActionTypeFade {
+			problems = append(problems, "only fade actions have an fps")
+		} else if err := validateFPS(action.GetFps()); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	switch action.GetType() {
+	case ScriptActionType_ActionTypeSet:
+		if err := validateOSCAddress(action.GetAddress()); err != nil {
+			problems = append(problems, err.Error())
+		}
+	case ScriptActionType_ActionTypeFade:
+		if err := validateOSCAddress(action.GetAddress()); err != nil {
+			problems = append(problems, err.Error())
+		}
+		values := action.GetValues()
+		if len(values) < 2 {
+			problems = append(problems, fmt.Sprintf("fade needs 2 values, has %d", len(values)))
+			break
+		}
+		for i, v := range values[:2] {
+			if _, ok := v.GetValue().(*OSCValue_Float32); !ok {
+				problems = append(problems, fmt.Sprintf("fade value %d is not a float32", i))
+			}
+		}
+	case ScriptActionType_ActionTypeSleep:
+	case ScriptActionType_ActionTypeMaster:
+		if action.GetMaster() == "" {
+			problems = append(problems, "master action has no master")
+		}
+		values := action.GetValues()
+		if len(values) != 1 {
+			problems = append(problems, fmt.Sprintf("master action needs 1 value, has %d", len(values)))
+			break
+		}
+		if f, ok := values[0].GetValue().(*OSCValue_Float32); !ok {
+			problems = append(problems, "master level is not a float32")
+		} else if f.Float32 < 0 || f.Float32 > 1 {
+			problems = append(problems, fmt.Sprintf("master level %v is outside 0.0-1.0", f.Float32))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown action type %d", action.GetType()))
+	}
+	return problems
+}
+
+// validateScriptSize returns an error if the script with the given ID is
+// bigger than limits allow
+func validateScriptSize(id int32, script *Script, limits *RunnerLimits) []*ConfigError {
+	if err := checkScriptSize(script, limits); err != nil {
+		return []*ConfigError{{
+			Subject:     &ConfigError_ScriptId{ScriptId: id},
+			ActionIndex: -1,
+			Message:     err.Error(),
+		}}
+	}
+	return nil
+}
+
+// validateScript checks the script with the given ID, returning an error for
+// each problem found
+func validateScript(id int32, script *Script) []*ConfigError {
+	if script == nil {
+		return []*ConfigError{{
+			Subject:     &ConfigError_ScriptId{ScriptId: id},
+			ActionIndex: -1,
+			Message:     "script is empty",
+		}}
+	}
+	var cfgErrs []*ConfigError
+	if script.GetBpm() != 0 {
+		if err := validateBPM(script.GetBpm()); err != nil {
+			cfgErrs = append(cfgErrs, &ConfigError{
+				Subject:     &ConfigError_ScriptId{ScriptId: id},
+				ActionIndex: -1,
+				Message:     err.Error(),
+			})
+		}
+	}
+	if script.GetFps() != 0 {
+		if err := validateFPS(script.GetFps()); err != nil {
+			cfgErrs = append(cfgErrs, &ConfigError{
+				Subject:     &ConfigError_ScriptId{ScriptId: id},
+				ActionIndex: -1,
+				Message:     err.Error(),
+			})
+		}
+	}
+	if _, ok := restartPolicyNames[script.GetRestart()]; !ok {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_ScriptId{ScriptId: id},
+			ActionIndex: -1,
+			Message:     fmt.Sprintf("unknown restart policy %d", script.GetRestart()),
+		})
+	}
+	for i, action := range script.GetActions() {
+		for _, problem := range validateAction(action) {
+			cfgErrs = append(cfgErrs, &ConfigError{
+				Subject:     &ConfigError_ScriptId{ScriptId: id},
+				ActionIndex: int32(i),
+				Message:     problem,
+			})
+		}
+	}
+	if len(script.GetTracks()) > 0 && len(script.GetActions()) > 0 {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_ScriptId{ScriptId: id},
+			ActionIndex: -1,
+			Message:     "script has both actions and tracks",
+		})
+	}
+	for i, track := range script.GetTracks() {
+		for _, problem := range validateTrack(track) {
+			cfgErrs = append(cfgErrs, &ConfigError{
+				Subject:     &ConfigError_ScriptId{ScriptId: id},
+				ActionIndex: -1,
+				Message:     fmt.Sprintf("track %d: %s", i, problem),
+			})
+		}
+	}
+	return cfgErrs
+}
+
+// validateTrigger checks the trigger with the given ID against the scripts in
+// cfg, returning an error for each problem found
+func validateTrigger(cfg *Config, id string, trigger *Trigger) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_TriggerId{TriggerId: id},
+			ActionIndex: -1,
+			Message:     message,
+		})
+	}
+	if id == "" {
+		addErr("trigger name is empty")
+	}
+	if trigger.GetPanic() && trigger.GetTapTempo() {
+		addErr("trigger can't both panic and tap the tempo")
+	}
+	runsScript := trigger.runsScript()
+	if trigger.GetTarget() == "" && runsScript {
+		addErr("trigger has no target")
+	}
+	script, present := cfg.GetScripts()[trigger.GetScriptId()]
+	if !present && runsScript {
+		addErr(fmt.Sprintf("trigger references nonexistent script %d", trigger.GetScriptId()))
+	}
+	if present && runsScript {
+		for _, role := range missingRoles(script, trigger.GetRoles()) {
+			addErr(fmt.Sprintf("trigger has no target for role %q", role))
+		}
+	}
+	return cfgErrs
+}
+
+// validateScene checks the scene with the given ID, returning an error for
+// each problem found
+func validateScene(id int32, scene *Scene) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(index int, message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_SceneId{SceneId: id},
+			ActionIndex: int32(index),
+			Message:     message,
+		})
+	}
+	if scene.GetName() == "" {
+		addErr(-1, "scene name is empty")
+	}
+	for i, sv := range scene.GetValues() {
+		if sv.GetTarget() == "" {
+			addErr(i, "value has no target")
+		}
+		if err := validateOSCAddress(sv.GetAddress()); err != nil {
+			addErr(i, err.Error())
+		}
+	}
+	return cfgErrs
+}
+
+// validateCueList checks the cue list with the given name against the scripts
+// and scenes in cfg, returning an error for each problem found
+func validateCueList(cfg *Config, name string, list *CueList) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(index int, message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_CueList{CueList: name},
+			ActionIndex: int32(index),
+			Message:     message,
+		})
+	}
+	if name == "" {
+		addErr(-1, "cue list name is empty")
+	}
+	for i, cue := range list.GetCues() {
+		switch cue.GetSubject().(type) {
+		case *Cue_ScriptId:
+			script, present := cfg.GetScripts()[cue.GetScriptId()]
+			if !present {
+				addErr(i, fmt.Sprintf("cue references nonexistent script %d", cue.GetScriptId()))
+			}
+			for _, role := range missingRoles(script, cue.GetRoles()) {
+				addErr(i, fmt.Sprintf("cue has no target for role %q", role))
+			}
+			if cue.GetTarget() == "" {
+				addErr(i, "script cue has no target")
+			}
+		case *Cue_SceneId:
+			if _, present := cfg.GetScenes()[cue.GetSceneId()]; !present {
+				addErr(i, fmt.Sprintf("cue references nonexistent scene %d", cue.GetSceneId()))
+			}
+		default:
+			addErr(i, "cue has no script or scene")
+		}
+	}
+	return cfgErrs
+}
+
+// validateMaster checks the master with the given name, returning an error
+// for each problem found
+func validateMaster(name string, master *Master) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(index int, message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_Master{Master: name},
+			ActionIndex: int32(index),
+			Message:     message,
+		})
+	}
+	if name == "" {
+		addErr(-1, "master name is empty")
+	}
+	for i, ma := range master.GetAddresses() {
+		if ma.GetTarget() == "" {
+			addErr(i, "master address has no target")
+		}
+		if err := validateAddressPattern(ma.GetAddress()); err != nil {
+			addErr(i, err.Error())
+		}
+	}
+	return cfgErrs
+}
+
+// validateSafeState checks the safe state for target, returning an error for
+// each problem found. Safe states are sent all at once, so they may only
+// contain set actions.
+func validateSafeState(target string, ss *SafeState) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(index int, message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_SafeState{SafeState: target},
+			ActionIndex: int32(index),
+			Message:     message,
+		})
+	}
+	if target == "" {
+		addErr(-1, "safe state has no target")
+	}
+	for i, action := range ss.GetActions() {
+		if action.GetType() != ScriptActionType_ActionTypeSet {
+			addErr(i, "safe states may only contain set actions")
+			continue
+		}
+		if action.GetTarget() != "" || action.GetRole() != "" {
+			addErr(i, "safe state actions can't have a target or role")
+		}
+		for _, problem := range validateAction(action) {
+			addErr(i, problem)
+		}
+	}
+	return cfgErrs
+}
+
+// validateLimits checks the limits for target, returning an error for each
+// problem found
+func validateLimits(target string, tl *TargetLimits) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(index int, message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_Limits{Limits: target},
+			ActionIndex: int32(index),
+			Message:     message,
+		})
+	}
+	if target == "" {
+		addErr(-1, "limits have no target")
+	}
+	for i, limit := range tl.GetAddresses() {
+		if err := validateAddressPattern(limit.GetAddress()); err != nil {
+			addErr(i, err.Error())
+		}
+		if r := limit.GetRange(); r != nil && r.GetMin() > r.GetMax() {
+			addErr(i, fmt.Sprintf("range minimum %v is above maximum %v", r.GetMin(), r.GetMax()))
+		}
+		if limit.GetMaxRate() < 0 {
+			addErr(i, fmt.Sprintf("max rate %v is negative", limit.GetMaxRate()))
+		}
+		for _, vt := range limit.GetAllowedTypes() {
+			if _, known := OSCValueType_name[int32(vt)]; !known {
+				addErr(i, fmt.Sprintf("unknown value type %d", vt))
+			}
+		}
+		if _, known := LimitMode_name[int32(limit.GetMode())]; !known {
+			addErr(i, fmt.Sprintf("unknown limit mode %d", limit.GetMode()))
+		}
+	}
+	return cfgErrs
+}
+
+// validateTargetGroup checks the target group with the given name against the
+// other groups in cfg, returning an error for each problem found
+func validateTargetGroup(cfg *Config, name string, group *TargetGroup) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(index int, message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_TargetGroup{TargetGroup: name},
+			ActionIndex: int32(index),
+			Message:     message,
+		})
+	}
+	if name == "" {
+		addErr(-1, "target group name is empty")
+	}
+	if len(group.GetMembers()) == 0 {
+		addErr(-1, "target group has no members")
+	}
+	for i, member := range group.GetMembers() {
+		if member.GetTarget() == "" {
+			addErr(i, "member has no target")
+		}
+		if _, present := cfg.GetTargetGroups()[member.GetTarget()]; present {
+			addErr(i, fmt.Sprintf("member %q is a target group", member.GetTarget()))
+		}
+		if member.GetPrefixFrom() != "" {
+			if err := validateAddressPattern(member.GetPrefixFrom()); err != nil {
+				addErr(i, "prefix from: "+err.Error())
+			}
+		}
+		if member.GetPrefixTo() != "" {
+			if err := validateAddressPattern(member.GetPrefixTo()); err != nil {
+				addErr(i, "prefix to: "+err.Error())
+			}
+		}
+	}
+	return cfgErrs
+}
+
+// validateOutput checks the output settings for target, returning an error for
+// each problem found
+func validateOutput(target string, output *TargetOutput) []*ConfigError {
+	var cfgErrs []*ConfigError
+	addErr := func(message string) {
+		cfgErrs = append(cfgErrs, &ConfigError{
+			Subject:     &ConfigError_Output{Output: target},
+			ActionIndex: -1,
+			Message:     message,
+		})
+	}
+	if target == "" {
+		addErr("output has no target")
+	}
+	if output.GetFps() != 0 {
+		if err := validateFPS(output.GetFps()); err != nil {
+			addErr(err.Error())
+		}
+	}
+	if output.GetResolution() < 0 {
+		addErr(fmt.Sprintf("resolution %v is negative", output.GetResolution()))
+	}
+	return cfgErrs
+}
+
+// validateConfig checks every script, trigger, scene, cue list, master, safe
+// state, limit, target group, and output in cfg, returning an error for each
+// problem found. The returned errors are ordered by script ID and action, then
+// by trigger ID, then by scene ID and value, then by cue list name and cue,
+// then by master name and address, then by safe state target and action, then
+// by limits target and address, then by target group name and member, then by
+// output target.
+func validateConfig(cfg *Config) []*ConfigError {
+	var cfgErrs []*ConfigError
+
+	scriptIDs := make([]int32, 0, len(cfg.GetScripts()))
+	for id := range cfg.GetScripts() {
+		scriptIDs = append(scriptIDs, id)
+	}
+	sort.Slice(scriptIDs, func(i, j int) bool { return scriptIDs[i] < scriptIDs[j] })
+	for _, id := range scriptIDs {
+		cfgErrs = append(cfgErrs, validateScript(id, cfg.GetScripts()[id])...)
+		cfgErrs = append(cfgErrs, validateScriptSize(id, cfg.GetScripts()[id], cfg.GetRunnerLimits())...)
+	}
+
+	triggerIDs := make([]string, 0, len(cfg.GetTriggers()))
+	for id := range cfg.GetTriggers() {
+		triggerIDs = append(triggerIDs, id)
+	}
+	sort.Strings(triggerIDs)
+	for _, id := range triggerIDs {
+		cfgErrs = append(cfgErrs, validateTrigger(cfg, id, cfg.GetTriggers()[id])...)
+	}
+
+	sceneIDs := make([]int32, 0, len(cfg.GetScenes()))
+	for id := range cfg.GetScenes() {
+		sceneIDs = append(sceneIDs, id)
+	}
+	sort.Slice(sceneIDs, func(i, j int) bool { return sceneIDs[i] < sceneIDs[j] })
+	for _, id := range sceneIDs {
+		cfgErrs = append(cfgErrs, validateScene(id, cfg.GetScenes()[id])...)
+	}
+
+	cueListNames := make([]string, 0, len(cfg.GetCueLists()))
+	for name := range cfg.GetCueLists() {
+		cueListNames = append(cueListNames, name)
+	}
+	sort.Strings(cueListNames)
+	for _, name := range cueListNames {
+		cfgErrs = append(cfgErrs, validateCueList(cfg, name, cfg.GetCueLists()[name])...)
+	}
+
+	masterNames := make([]string, 0, len(cfg.GetMasters()))
+	for name := range cfg.GetMasters() {
+		masterNames = append(masterNames, name)
+	}
+	sort.Strings(masterNames)
+	for _, name := range masterNames {
+		cfgErrs = append(cfgErrs, validateMaster(name, cfg.GetMasters()[name])...)
+	}
+
+	safeStateTargets := make([]string, 0, len(cfg.GetSafeStates()))
+	for target := range cfg.GetSafeStates() {
+		safeStateTargets = append(safeStateTargets, target)
+	}
+	sort.Strings(safeStateTargets)
+	for _, target := range safeStateTargets {
+		cfgErrs = append(cfgErrs, validateSafeState(target, cfg.GetSafeStates()[target])...)
+	}
+
+	limitTargets := make([]string, 0, len(cfg.GetLimits()))
+	for target := range cfg.GetLimits() {
+		limitTargets = append(limitTargets, target)
+	}
+	sort.Strings(limitTargets)
+	for _, target := range limitTargets {
+		cfgErrs = append(cfgErrs, validateLimits(target, cfg.GetLimits()[target])...)
+	}
+
+	targetGroupNames := make([]string, 0, len(cfg.GetTargetGroups()))
+	for name := range cfg.GetTargetGroups() {
+		targetGroupNames = append(targetGroupNames, name)
+	}
+	sort.Strings(targetGroupNames)
+	for _, name := range targetGroupNames {
+		cfgErrs = append(cfgErrs, validateTargetGroup(cfg, name, cfg.GetTargetGroups()[name])...)
+	}
+
+	outputTargets := make([]string, 0, len(cfg.GetOutputs()))
+	for target := range cfg.GetOutputs() {
+		outputTargets = append(outputTargets, target)
+	}
+	sort.Strings(outputTargets)
+	for _, target := range outputTargets {
+		cfgErrs = append(cfgErrs, validateOutput(target, cfg.GetOutputs()[target])...)
+	}
+
+	return cfgErrs
+}
+
+// describe a ConfigError for logs and error details
+func (ce *ConfigError) describe() string {
+	switch ce.GetSubject().(type) {
+	case *ConfigError_TriggerId:
+		return fmt.Sprintf("trigger %q: %s", ce.GetTriggerId(), ce.GetMessage())
+	case *ConfigError_SceneId:
+		if ce.GetActionIndex() < 0 {
+			return fmt.Sprintf("scene %d: %s", ce.GetSceneId(), ce.GetMessage())
+		}
+		return fmt.Sprintf("scene %d value %d: %s", ce.GetSceneId(), ce.GetActionIndex(), ce.GetMessage())
+	case *ConfigError_CueList:
+		if ce.GetActionIndex() < 0 {
+			return fmt.Sprintf("cue list %q: %s", ce.GetCueList(), ce.GetMessage())
+		}
+		return fmt.Sprintf("cue list %q cue %d: %s", ce.GetCueList(), ce.GetActionIndex(), ce.GetMessage())
+	case *ConfigError_Master:
+		if ce.GetActionIndex() < 0 {
+			return fmt.Sprintf("master %q: %s", ce.GetMaster(), ce.GetMessage())
+		}
+		return fmt.Sprintf("master %q address %d: %s", ce.GetMaster(), ce.GetActionIndex(), ce.GetMessage())
+	case *ConfigError_SafeState:
+		if ce.GetActionIndex() < 0 {
+			return fmt.Sprintf("safe state %q: %s", ce.GetSafeState(), ce.GetMessage())
+		}
+		return fmt.Sprintf("safe state %q action %d: %s", ce.GetSafeState(), ce.GetActionIndex(), ce.GetMessage())
+	case *ConfigError_Limits:
+		if ce.GetActionIndex() < 0 {
+			return fmt.Sprintf("limits for %q: %s", ce.GetLimits(), ce.GetMessage())
+		}
+		return fmt.Sprintf("limits for %q address %d: %s", ce.GetLimits(), ce.GetActionIndex(), ce.GetMessage())
+	case *ConfigError_TargetGroup:
+		if ce.GetActionIndex() < 0 {
+			return fmt.Sprintf("target group %q: %s", ce.GetTargetGroup(), ce.GetMessage())
+		}
+		return fmt.Sprintf("target group %q member %d: %s", ce.GetTargetGroup(), ce.GetActionIndex(), ce.GetMessage())
+	case *ConfigError_Output:
+		return fmt.Sprintf("output for %q: %s", ce.GetOutput(), ce.GetMessage())
+	}
+	if ce.GetActionIndex() < 0 {
+		return fmt.Sprintf("script %d: %s", ce.GetScriptId(), ce.GetMessage())
+	}
+	return fmt.Sprintf("script %d action %d: %s", ce.GetScriptId(), ce.GetActionIndex(), ce.GetMessage())
+}
+
+// configErrorsError builds the bus error reported when a config fails
+// validation
+func configErrorsError(cfgErrs []*ConfigError) *core.Error {
+	details := make([]string, len(cfgErrs))
+	for i, ce := range cfgErrs {
+		details[i] = ce.describe()
+	}
+	return &core.Error{
+		Code:        int32(core.CommonErrorCode_BAD_REQUEST),
+		Detail:      core.String(strings.Join(details, "; ")),
+		UserMessage: core.String(fmt.Sprintf("invalid config: %d problem(s)", len(cfgErrs))),
+	}
+}
@@ -89,6 +89,9 @@ customElements.define('rosco-script-edit', ScriptEditor);
 function createScript(ws: Blockly.WorkspaceSvg, script: roscopb.Script): Blockly.BlockSvg {
     let scriptB = ws.newBlock(blocks.BLOCK_TYPE_SCRIPT);
     scriptB.setFieldValue(script.name, blocks.FIELD_NAME_NAME);
+    scriptB.setFieldValue(script.bpm, blocks.FIELD_NAME_BPM);
+    scriptB.setFieldValue(script.fps, blocks.FIELD_NAME_FPS);
+    scriptB.setFieldValue(script.restart.toString(), blocks.FIELD_NAME_RESTART);
 
     let actionBlocks = script.actions.map((action) => {
         switch (action.type) {
@@ -98,28 +101,57 @@ function createScript(ws: Blockly.WorkspaceSvg, script: roscopb.Script): Blockly
                 return createScriptActionFade(ws, action);
             case roscopb.ScriptActionType.ActionTypeSleep:
                 return createScriptActionSleep(ws, action);
+            case roscopb.ScriptActionType.ActionTypeMaster:
+                return createScriptActionMaster(ws, action);
             default:
                 throw `Unhandled action type ${action.type}`;
         }
     });
-    if (actionBlocks.length) {
-        scriptB.getInput(blocks.FIELD_NAME_ACTIONS).connection.connect(actionBlocks[0].previousConnection);
-        actionBlocks.reduce((prev, next) => {
-            prev.nextConnection.connect(next.previousConnection);
-            return next;
-        });
-        actionBlocks.forEach((block) => {
-            block.initSvg();
-            block.render();
-        });
-    }
+    connectStatements(scriptB, blocks.FIELD_NAME_ACTIONS, actionBlocks);
+    let trackBlocks = script.tracks.map((track) => createKeyframeTrack(ws, track));
+    connectStatements(scriptB, blocks.FIELD_NAME_TRACKS, trackBlocks);
 
     return scriptB;
 }
 
+// connectStatements connects a list of statement blocks to a parent's input
+function connectStatements(parent: Blockly.BlockSvg, input: string, statements: Blockly.BlockSvg[]) {
+    if (!statements.length) {
+        return;
+    }
+    parent.getInput(input).connection.connect(statements[0].previousConnection);
+    statements.reduce((prev, next) => {
+        prev.nextConnection.connect(next.previousConnection);
+        return next;
+    });
+    statements.forEach((block) => {
+        block.initSvg();
+        block.render();
+    });
+}
+
+function createKeyframeTrack(ws: Blockly.WorkspaceSvg, track: roscopb.KeyframeTrack): Blockly.BlockSvg {
+    let block = ws.newBlock(blocks.BLOCK_TYPE_KEYFRAME_TRACK);
+    block.setFieldValue(track.address, blocks.FIELD_NAME_ADDRESS);
+    block.setFieldValue(track.target, blocks.FIELD_NAME_TARGET);
+    block.setFieldValue(track.role, blocks.FIELD_NAME_ROLE);
+    block.setFieldValue(track.fps, blocks.FIELD_NAME_FPS);
+    let keyframeBlocks = track.keyframes.map((keyframe) => {
+        let kb = ws.newBlock(blocks.BLOCK_TYPE_KEYFRAME);
+        kb.setFieldValue(keyframe.timeMs, blocks.FIELD_NAME_TIME);
+        kb.setFieldValue(keyframe.value, blocks.FIELD_NAME_VALUE);
+        kb.setFieldValue(keyframe.curve.toString(), blocks.FIELD_NAME_CURVE);
+        return kb;
+    });
+    connectStatements(block, blocks.FIELD_NAME_KEYFRAMES, keyframeBlocks);
+    return block;
+}
+
 function createScriptActionSet(ws: Blockly.WorkspaceSvg, action: roscopb.ScriptAction): Blockly.BlockSvg {
     let block = ws.newBlock(blocks.BLOCK_TYPE_SCRIPT_ACTION_SET);
     block.setFieldValue(action.address, blocks.FIELD_NAME_ADDRESS);
+    block.setFieldValue(action.target, blocks.FIELD_NAME_TARGET);
+    block.setFieldValue(action.role, blocks.FIELD_NAME_ROLE);
 
     if (action.values.length) {
         let valueBlock = createOSCValue(ws, action.values[0]);
@@ -133,6 +165,8 @@ function createScriptActionSet(ws: Blockly.WorkspaceSvg, action: roscopb.ScriptA
 function createScriptActionFade(ws: Blockly.WorkspaceSvg, action: roscopb.ScriptAction): Blockly.BlockSvg {
     let block = ws.newBlock(blocks.BLOCK_TYPE_SCRIPT_ACTION_FADE);
     block.setFieldValue(action.address, blocks.FIELD_NAME_ADDRESS);
+    block.setFieldValue(action.target, blocks.FIELD_NAME_TARGET);
+    block.setFieldValue(action.role, blocks.FIELD_NAME_ROLE);
 
     if (action.values.length) {
         block.setFieldValue(action.values[0].value.value, blocks.FIELD_NAME_FROM);
@@ -141,6 +175,9 @@ function createScriptActionFade(ws: Blockly.WorkspaceSvg, action: roscopb.Script
         block.setFieldValue(action.values[1].value.value, blocks.FIELD_NAME_TO);
     }
     block.setFieldValue(action.durationMs, blocks.FIELD_NAME_DURATION);
+    block.setFieldValue(action.beats, blocks.FIELD_NAME_BEATS);
+    block.setFieldValue(action.bars, blocks.FIELD_NAME_BARS);
+    block.setFieldValue(action.fps, blocks.FIELD_NAME_FPS);
 
     return block;
 }
@@ -148,6 +185,17 @@ function createScriptActionFade(ws: Blockly.WorkspaceSvg, action: roscopb.Script
 function createScriptActionSleep(ws: Blockly.WorkspaceSvg, action: roscopb.ScriptAction): Blockly.BlockSvg {
     let block = ws.newBlock(blocks.BLOCK_TYPE_SCRIPT_ACTION_SLEEP);
     block.setFieldValue(action.durationMs, blocks.FIELD_NAME_DURATION);
+    block.setFieldValue(action.beats, blocks.FIELD_NAME_BEATS);
+    block.setFieldValue(action.bars, blocks.FIELD_NAME_BARS);
+    return block;
+}
+
+function createScriptActionMaster(ws: Blockly.WorkspaceSvg, action: roscopb.ScriptAction): Blockly.BlockSvg {
+    let block = ws.newBlock(blocks.BLOCK_TYPE_SCRIPT_ACTION_MASTER);
+    block.setFieldValue(action.master, blocks.FIELD_NAME_MASTER);
+    if (action.values.length) {
+        block.setFieldValue(action.values[0].value.value, blocks.FIELD_NAME_LEVEL);
+    }
     return block;
 }
 
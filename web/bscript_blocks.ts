@@ -12,14 +12,30 @@ const BLOCK_TYPE_SCRIPT = 'script';
 const BLOCK_TYPE_SCRIPT_ACTION_SET = 'script_action_set';
 const BLOCK_TYPE_SCRIPT_ACTION_FADE = 'script_action_fade';
 const BLOCK_TYPE_SCRIPT_ACTION_SLEEP = 'script_action_sleep';
+const BLOCK_TYPE_SCRIPT_ACTION_MASTER = 'script_action_master';
+const BLOCK_TYPE_KEYFRAME_TRACK = 'keyframe_track';
+const BLOCK_TYPE_KEYFRAME = 'keyframe';
 
 const FIELD_NAME_ACTIONS = 'ACTIONS';
 const FIELD_NAME_ADDRESS = 'ADDRESS';
+const FIELD_NAME_BARS = 'BARS';
+const FIELD_NAME_BEATS = 'BEATS';
+const FIELD_NAME_BPM = 'BPM';
+const FIELD_NAME_CURVE = 'CURVE';
 const FIELD_NAME_DURATION = 'DURATION';
+const FIELD_NAME_FPS = 'FPS';
 const FIELD_NAME_FROM = 'FROM';
+const FIELD_NAME_KEYFRAMES = 'KEYFRAMES';
+const FIELD_NAME_LEVEL = 'LEVEL';
+const FIELD_NAME_MASTER = 'MASTER';
 const FIELD_NAME_NAME = 'NAME';
 const FIELD_NAME_OSC_VALUE = 'OSC_VALUE';
+const FIELD_NAME_RESTART = 'RESTART';
+const FIELD_NAME_ROLE = 'ROLE';
+const FIELD_NAME_TARGET = 'TARGET';
+const FIELD_NAME_TIME = 'TIME';
 const FIELD_NAME_TO = 'TO';
+const FIELD_NAME_TRACKS = 'TRACKS';
 const FIELD_NAME_TYPE = 'TYPE';
 const FIELD_NAME_VALUE = 'VALUE';
 
@@ -29,34 +45,69 @@ const CALLBACK_KEY_SAVE = 'SAVE';
 
 const CONNECT_SET_ACTION = 'set_action';
 const CONNECT_OSC_VALUE = 'osc_value';
+const CONNECT_TRACK = 'track';
+const CONNECT_KEYFRAME = 'keyframe';
 
 const blocks = Blockly.common.createBlockDefinitionsFromJsonArray([
     {
         "type": BLOCK_TYPE_SCRIPT,
-        "message0": "Script %1\nActions: %2",
+        "message0": "Script %1\nBPM: %2\nFPS: %3\nOn Restart: %4\nActions: %5\nTracks: %6",
         "args0": [
             {
                 "type": "field_input",
                 "name": FIELD_NAME_NAME,
                 "text": "new script",
             },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_BPM,
+                "min": 0,
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_FPS,
+                "min": 0,
+            },
+            {
+                "type": "field_dropdown",
+                "name": FIELD_NAME_RESTART,
+                "options": [
+                    ["abort", roscopb.RestartPolicy.RestartAbort.toString()],
+                    ["resume", roscopb.RestartPolicy.RestartResume.toString()],
+                ],
+            },
             {
                 "type": "input_statement",
                 "name": FIELD_NAME_ACTIONS,
                 "check": CONNECT_SET_ACTION,
             },
+            {
+                "type": "input_statement",
+                "name": FIELD_NAME_TRACKS,
+                "check": CONNECT_TRACK,
+            },
         ],
         "colour": '210',
     },
     {
         "type": BLOCK_TYPE_SCRIPT_ACTION_SET,
-        "message0": "Script Action Set\nAddress: %1\nValue: %2",
+        "message0": "Script Action Set\nAddress: %1\nTarget: %2\nRole: %3\nValue: %4",
         "args0": [
             {
                 "type": "field_input",
                 "name": FIELD_NAME_ADDRESS,
                 "text": "",
             },
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_TARGET,
+                "text": "",
+            },
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_ROLE,
+                "text": "",
+            },
             {
                 "type": "input_value",
                 "name": FIELD_NAME_OSC_VALUE,
@@ -69,13 +120,23 @@ const blocks = Blockly.common.createBlockDefinitionsFromJsonArray([
     },
     {
         "type": BLOCK_TYPE_SCRIPT_ACTION_FADE,
-        "message0": "Script Action Fade\nAddress: %1\nFrom: %2\nTo: %3\nDuration (ms): %4",
+        "message0": "Script Action Fade\nAddress: %1\nTarget: %2\nRole: %3\nFrom: %4\nTo: %5\nDuration (ms): %6\nBeats: %7\nBars: %8\nFPS: %9",
         "args0": [
             {
                 "type": "field_input",
                 "name": FIELD_NAME_ADDRESS,
                 "text": "",
             },
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_TARGET,
+                "text": "",
+            },
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_ROLE,
+                "text": "",
+            },
             {
                 "type": "field_number",
                 "name": FIELD_NAME_FROM,
@@ -88,6 +149,19 @@ const blocks = Blockly.common.createBlockDefinitionsFromJsonArray([
                 "type": "field_number",
                 "name": FIELD_NAME_DURATION,
             },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_BEATS,
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_BARS,
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_FPS,
+                "min": 0,
+            },
         ],
         "previousStatement": CONNECT_SET_ACTION,
         "nextStatement": CONNECT_SET_ACTION,
@@ -95,18 +169,109 @@ const blocks = Blockly.common.createBlockDefinitionsFromJsonArray([
     },
     {
         "type": BLOCK_TYPE_SCRIPT_ACTION_SLEEP,
-        "message0": "Script Action Sleep\nDuration (ms): %1",
+        "message0": "Script Action Sleep\nDuration (ms): %1\nBeats: %2\nBars: %3",
         "args0": [
             {
                 "type": "field_number",
                 "name": FIELD_NAME_DURATION,
                 "text": "",
             },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_BEATS,
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_BARS,
+            },
+        ],
+        "previousStatement": CONNECT_SET_ACTION,
+        "nextStatement": CONNECT_SET_ACTION,
+        "colour": '210',
+    },
+    {
+        "type": BLOCK_TYPE_SCRIPT_ACTION_MASTER,
+        "message0": "Script Action Master\nMaster: %1\nLevel: %2",
+        "args0": [
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_MASTER,
+                "text": "",
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_LEVEL,
+                "value": 1,
+                "min": 0,
+                "max": 1,
+            },
         ],
         "previousStatement": CONNECT_SET_ACTION,
         "nextStatement": CONNECT_SET_ACTION,
         "colour": '210',
     },
+    {
+        "type": BLOCK_TYPE_KEYFRAME_TRACK,
+        "message0": "Keyframe Track\nAddress: %1\nTarget: %2\nRole: %3\nFPS: %4\nKeyframes: %5",
+        "args0": [
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_ADDRESS,
+                "text": "",
+            },
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_TARGET,
+                "text": "",
+            },
+            {
+                "type": "field_input",
+                "name": FIELD_NAME_ROLE,
+                "text": "",
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_FPS,
+                "min": 0,
+            },
+            {
+                "type": "input_statement",
+                "name": FIELD_NAME_KEYFRAMES,
+                "check": CONNECT_KEYFRAME,
+            },
+        ],
+        "previousStatement": CONNECT_TRACK,
+        "nextStatement": CONNECT_TRACK,
+        "colour": '260',
+    },
+    {
+        "type": BLOCK_TYPE_KEYFRAME,
+        "message0": "Keyframe\nTime (ms): %1\nValue: %2\nCurve: %3",
+        "args0": [
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_TIME,
+                "min": 0,
+            },
+            {
+                "type": "field_number",
+                "name": FIELD_NAME_VALUE,
+            },
+            {
+                "type": "field_dropdown",
+                "name": FIELD_NAME_CURVE,
+                "options": [
+                    ["linear", roscopb.FadeCurve.CurveLinear.toString()],
+                    ["ease in", roscopb.FadeCurve.CurveEaseIn.toString()],
+                    ["ease out", roscopb.FadeCurve.CurveEaseOut.toString()],
+                    ["ease in and out", roscopb.FadeCurve.CurveEaseInOut.toString()],
+                ],
+            },
+        ],
+        "previousStatement": CONNECT_KEYFRAME,
+        "nextStatement": CONNECT_KEYFRAME,
+        "colour": '260',
+    },
     {
         "type": BLOCK_TYPE_OSC_NIL,
         "message0": "nil",
@@ -218,10 +383,14 @@ generator.forBlock[BLOCK_TYPE_OSC_FALSE] = function (block, generator) {
 
 generator.forBlock[BLOCK_TYPE_SCRIPT_ACTION_SET] = function (block, generator) {
     const address = block.getFieldValue(FIELD_NAME_ADDRESS);
+    const target = block.getFieldValue(FIELD_NAME_TARGET);
+    const role = block.getFieldValue(FIELD_NAME_ROLE);
     const value = generator.valueToCode(block, FIELD_NAME_OSC_VALUE, Order.ATOMIC);
     return `{
     "type": ${roscopb.ScriptActionType.ActionTypeSet},
     "address": ${JSON.stringify(address)},
+    "target": ${JSON.stringify(target)},
+    "role": ${JSON.stringify(role)},
     "values": [
         ${value}
     ]
@@ -230,40 +399,104 @@ generator.forBlock[BLOCK_TYPE_SCRIPT_ACTION_SET] = function (block, generator) {
 
 generator.forBlock[BLOCK_TYPE_SCRIPT_ACTION_FADE] = function (block, generator) {
     const address = block.getFieldValue(FIELD_NAME_ADDRESS);
+    const target = block.getFieldValue(FIELD_NAME_TARGET);
+    const role = block.getFieldValue(FIELD_NAME_ROLE);
     const from = block.getFieldValue(FIELD_NAME_FROM);
     const to = block.getFieldValue(FIELD_NAME_TO);
     const duration = block.getFieldValue(FIELD_NAME_DURATION);
+    const beats = block.getFieldValue(FIELD_NAME_BEATS);
+    const bars = block.getFieldValue(FIELD_NAME_BARS);
+    const fps = block.getFieldValue(FIELD_NAME_FPS);
     return `{
     "type": ${roscopb.ScriptActionType.ActionTypeFade},
     "address": ${JSON.stringify(address)},
+    "target": ${JSON.stringify(target)},
+    "role": ${JSON.stringify(role)},
     "values": [
         {"float32": ${from}},
         {"float32": ${to}}
     ],
-    "duration_ms": ${duration}
+    "duration_ms": ${duration},
+    "beats": ${beats},
+    "bars": ${bars},
+    "fps": ${fps}
 }`;
 }
 
 generator.forBlock[BLOCK_TYPE_SCRIPT_ACTION_SLEEP] = function (block, generator) {
     const duration = block.getFieldValue(FIELD_NAME_DURATION);
+    const beats = block.getFieldValue(FIELD_NAME_BEATS);
+    const bars = block.getFieldValue(FIELD_NAME_BARS);
     return `{
     "type": ${roscopb.ScriptActionType.ActionTypeSleep},
-    "duration_ms": ${duration}
+    "duration_ms": ${duration},
+    "beats": ${beats},
+    "bars": ${bars}
+}`;
+}
+
+generator.forBlock[BLOCK_TYPE_SCRIPT_ACTION_MASTER] = function (block, generator) {
+    const master = block.getFieldValue(FIELD_NAME_MASTER);
+    const level = block.getFieldValue(FIELD_NAME_LEVEL);
+    return `{
+    "type": ${roscopb.ScriptActionType.ActionTypeMaster},
+    "master": ${JSON.stringify(master)},
+    "values": [
+        {"float32": ${level}}
+    ]
 }`;
 }
 
 generator.forBlock[BLOCK_TYPE_SCRIPT] = function (block, generator) {
     const name = block.getFieldValue(FIELD_NAME_NAME);
+    const bpm = block.getFieldValue(FIELD_NAME_BPM);
+    const fps = block.getFieldValue(FIELD_NAME_FPS);
+    const restart = block.getFieldValue(FIELD_NAME_RESTART);
     const actions = generator.statementToCode(block, FIELD_NAME_ACTIONS);
+    const tracks = generator.statementToCode(block, FIELD_NAME_TRACKS);
     return `
 {
     "name": ${JSON.stringify(name)},
+    "bpm": ${bpm},
+    "fps": ${fps},
+    "restart": ${restart},
     "actions": [
         ${actions}
+    ],
+    "tracks": [
+        ${tracks}
+    ]
+}`;
+}
+
+generator.forBlock[BLOCK_TYPE_KEYFRAME_TRACK] = function (block, generator) {
+    const address = block.getFieldValue(FIELD_NAME_ADDRESS);
+    const target = block.getFieldValue(FIELD_NAME_TARGET);
+    const role = block.getFieldValue(FIELD_NAME_ROLE);
+    const fps = block.getFieldValue(FIELD_NAME_FPS);
+    const keyframes = generator.statementToCode(block, FIELD_NAME_KEYFRAMES);
+    return `{
+    "address": ${JSON.stringify(address)},
+    "target": ${JSON.stringify(target)},
+    "role": ${JSON.stringify(role)},
+    "fps": ${fps},
+    "keyframes": [
+        ${keyframes}
     ]
 }`;
 }
 
+generator.forBlock[BLOCK_TYPE_KEYFRAME] = function (block, generator) {
+    const time = block.getFieldValue(FIELD_NAME_TIME);
+    const value = block.getFieldValue(FIELD_NAME_VALUE);
+    const curve = block.getFieldValue(FIELD_NAME_CURVE);
+    return `{
+    "time_ms": ${time},
+    "value": ${value},
+    "curve": ${curve}
+}`;
+}
+
 generator.scrub_ = function (block, code, thisOnly) {
     const nextBlock =
         block.nextConnection && block.nextConnection.targetBlock();
@@ -311,6 +544,18 @@ const toolbox = {
             'kind': 'block',
             'type': BLOCK_TYPE_SCRIPT_ACTION_SLEEP,
         },
+        {
+            'kind': 'block',
+            'type': BLOCK_TYPE_SCRIPT_ACTION_MASTER,
+        },
+        {
+            'kind': 'block',
+            'type': BLOCK_TYPE_KEYFRAME_TRACK,
+        },
+        {
+            'kind': 'block',
+            'type': BLOCK_TYPE_KEYFRAME,
+        },
         {
             'kind': 'label',
             'text': 'OSC Values',
@@ -359,16 +604,32 @@ export {
     BLOCK_TYPE_SCRIPT_ACTION_SET,
     BLOCK_TYPE_SCRIPT_ACTION_FADE,
     BLOCK_TYPE_SCRIPT_ACTION_SLEEP,
+    BLOCK_TYPE_SCRIPT_ACTION_MASTER,
+    BLOCK_TYPE_KEYFRAME_TRACK,
+    BLOCK_TYPE_KEYFRAME,
     CALLBACK_KEY_CANCEL,
     CALLBACK_KEY_RUN,
     CALLBACK_KEY_SAVE,
     FIELD_NAME_ACTIONS,
     FIELD_NAME_ADDRESS,
+    FIELD_NAME_BARS,
+    FIELD_NAME_BEATS,
+    FIELD_NAME_BPM,
+    FIELD_NAME_CURVE,
     FIELD_NAME_DURATION,
+    FIELD_NAME_FPS,
     FIELD_NAME_FROM,
+    FIELD_NAME_KEYFRAMES,
+    FIELD_NAME_LEVEL,
+    FIELD_NAME_MASTER,
     FIELD_NAME_OSC_VALUE,
+    FIELD_NAME_RESTART,
     FIELD_NAME_NAME,
+    FIELD_NAME_ROLE,
+    FIELD_NAME_TARGET,
+    FIELD_NAME_TIME,
     FIELD_NAME_TO,
+    FIELD_NAME_TRACKS,
     FIELD_NAME_TYPE,
     FIELD_NAME_VALUE,
 };
\ No newline at end of file
@@ -43,6 +43,9 @@ class Cfg extends ValueUpdater<roscopb.Config> {
             .then((reply) => {
                 let csResp = roscopb.ConfigSetResponse.fromBinary(reply.message);
                 this.update(csResp.config);
+                if (reply.error) {
+                    throw reply.error;
+                }
             });
     }
 }
@@ -100,6 +103,126 @@ class Controller {
         return this._ready;
     }
 
+    private async _command(type: roscopb.MessageTypeCommand, message: Uint8Array): Promise<buspb.BusMessage> {
+        return bus.sendAnd(new buspb.BusMessage({
+            topic: TOPIC_COMMAND,
+            type,
+            message,
+        })).then((reply) => {
+            this._cfg.refresh();
+            if (reply.error) {
+                throw reply.error;
+            }
+            return reply;
+        });
+    }
+
+    async createScript(script: roscopb.Script): Promise<number> {
+        return this._command(
+            roscopb.MessageTypeCommand.SCRIPT_CREATE_REQ,
+            new roscopb.ScriptCreateRequest({ script, baseRevision: this._cfg.last.revision }).toBinary(),
+        ).then((reply) => roscopb.ScriptCreateResponse.fromBinary(reply.message).scriptId);
+    }
+
+    async updateScript(scriptId: number, script: roscopb.Script) {
+        return this._command(
+            roscopb.MessageTypeCommand.SCRIPT_UPDATE_REQ,
+            new roscopb.ScriptUpdateRequest({ scriptId, script, baseRevision: this._cfg.last.revision }).toBinary(),
+        );
+    }
+
+    async deleteScript(scriptId: number) {
+        return this._command(
+            roscopb.MessageTypeCommand.SCRIPT_DELETE_REQ,
+            new roscopb.ScriptDeleteRequest({ scriptId, baseRevision: this._cfg.last.revision }).toBinary(),
+        );
+    }
+
+    async createTrigger(triggerId: string, trigger: roscopb.Trigger) {
+        return this._command(
+            roscopb.MessageTypeCommand.TRIGGER_CREATE_REQ,
+            new roscopb.TriggerCreateRequest({ triggerId, trigger, baseRevision: this._cfg.last.revision }).toBinary(),
+        );
+    }
+
+    async updateTrigger(triggerId: string, trigger: roscopb.Trigger) {
+        return this._command(
+            roscopb.MessageTypeCommand.TRIGGER_UPDATE_REQ,
+            new roscopb.TriggerUpdateRequest({ triggerId, trigger, baseRevision: this._cfg.last.revision }).toBinary(),
+        );
+    }
+
+    async deleteTrigger(triggerId: string) {
+        return this._command(
+            roscopb.MessageTypeCommand.TRIGGER_DELETE_REQ,
+            new roscopb.TriggerDeleteRequest({ triggerId, baseRevision: this._cfg.last.revision }).toBinary(),
+        );
+    }
+
+    async panic(): Promise<number> {
+        return bus.sendAnd(new buspb.BusMessage({
+            topic: TOPIC_REQUEST,
+            type: roscopb.MessageTypeRequest.PANIC_REQ,
+            message: new roscopb.PanicRequest().toBinary(),
+        })).then((reply) => {
+            if (reply.error) {
+                throw reply.error;
+            }
+            return roscopb.PanicResponse.fromBinary(reply.message).runnersCancelled;
+        });
+    }
+
+    async tapTempo(): Promise<roscopb.TempoTapResponse> {
+        return bus.sendAnd(new buspb.BusMessage({
+            topic: TOPIC_REQUEST,
+            type: roscopb.MessageTypeRequest.TEMPO_TAP_REQ,
+            message: new roscopb.TempoTapRequest().toBinary(),
+        })).then((reply) => {
+            if (reply.error) {
+                throw reply.error;
+            }
+            return roscopb.TempoTapResponse.fromBinary(reply.message);
+        });
+    }
+
+    async listRunners(): Promise<roscopb.RunnerStatus[]> {
+        return bus.sendAnd(new buspb.BusMessage({
+            topic: TOPIC_REQUEST,
+            type: roscopb.MessageTypeRequest.RUNNER_LIST_REQ,
+            message: new roscopb.RunnerListRequest().toBinary(),
+        })).then((reply) => {
+            if (reply.error) {
+                throw reply.error;
+            }
+            return roscopb.RunnerListResponse.fromBinary(reply.message).runners;
+        });
+    }
+
+    async listScheduledRuns(): Promise<roscopb.ScheduledRun[]> {
+        return bus.sendAnd(new buspb.BusMessage({
+            topic: TOPIC_REQUEST,
+            type: roscopb.MessageTypeRequest.SCHEDULED_RUN_LIST_REQ,
+            message: new roscopb.ScheduledRunListRequest().toBinary(),
+        })).then((reply) => {
+            if (reply.error) {
+                throw reply.error;
+            }
+            return roscopb.ScheduledRunListResponse.fromBinary(reply.message).runs;
+        });
+    }
+
+    async cancelScheduledRun(id: number): Promise<void> {
+        return bus.sendAnd(new buspb.BusMessage({
+            topic: TOPIC_REQUEST,
+            type: roscopb.MessageTypeRequest.SCHEDULED_RUN_CANCEL_REQ,
+            message: new roscopb.ScheduledRunCancelRequest({ id: id }).toBinary(),
+        })).then((reply) => {
+            if (reply.error) {
+                throw reply.error;
+            }
+        });
+    }
+
     sendOSC(address: string, osc: roscopb.OSCValue) {
         let script = new roscopb.Script({
             name: 'one-shot',
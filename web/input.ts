@@ -244,4 +244,69 @@ class FloatField {
     }
 }
 
-export { Duration, FloatField, OSCAddress, OSCValue };
\ No newline at end of file
+// NumberField is an optional number, 0 when it's empty
+class NumberField {
+    private _label: HTMLLabelElement;
+    private _input: HTMLInputElement;
+
+    constructor(label: string) {
+        let id = Math.random().toString();
+        this._input = document.createElement('input');
+        this._input.type = 'text';
+        this._input.id = id;
+        this._input.pattern = PATTERN_FLOAT;
+        this._label = document.createElement('label');
+        this._label.innerText = label;
+        this._label.setAttribute('for', id);
+    }
+
+    elements(): HTMLElement[] {
+        return [this._label, this._input];
+    }
+
+    set value(v: number) {
+        this._input.value = v ? v.toString() : '';
+    }
+
+    get value(): number {
+        return this._input.value ? parseFloat(this._input.value) : 0;
+    }
+
+    valid(): boolean {
+        return this._input.checkValidity();
+    }
+}
+
+class TextField {
+    private _label: HTMLLabelElement;
+    private _input: HTMLInputElement;
+
+    constructor(label: string, required = true) {
+        let id = Math.random().toString();
+        this._input = document.createElement('input');
+        this._input.type = 'text';
+        this._input.id = id;
+        this._input.required = required;
+        this._label = document.createElement('label');
+        this._label.innerText = label;
+        this._label.setAttribute('for', id);
+    }
+
+    elements(): HTMLElement[] {
+        return [this._label, this._input];
+    }
+
+    set value(v: string) {
+        this._input.value = v;
+    }
+
+    get value(): string {
+        return this._input.value;
+    }
+
+    valid(): boolean {
+        return this._input.checkValidity();
+    }
+}
+
+export { Duration, FloatField, NumberField, OSCAddress, OSCValue, TextField };
\ No newline at end of file
@@ -58,7 +58,24 @@ A <em>Script Action Fade</em> component will repeatedly send OSC messages to set
 <em>Address</em>, adjusting it smoothly over time. The <em>From</em> and <em>To</em> are float32
 values specifying the starting and ending value, respectively. <em>Duration</em> specifies how
 it should take to go from the <em>From</em> value to the <em>To</em> value, in milliseconds (ms)
-where one second is 1,000 milliseconds. Messages are sent 60 times per second.
+where one second is 1,000 milliseconds. Messages are sent 60 times per second unless the fade's
+<em>FPS</em>, the script's, or the target's output settings say otherwise, and a message isn't
+sent when its value hasn't changed.
+</p>
+
+<p>
+Instead of actions, a script can have <em>Keyframe Track</em> components. Each track automates
+one <em>Address</em> with <em>Keyframe</em> components giving its value at a <em>Time</em>
+after the script starts, in milliseconds. Between keyframes the value moves along the
+keyframe's <em>Curve</em>, and all of a script's tracks play at the same time. A script can
+have actions or tracks, but not both.
+</p>
+
+<p>
+The script's <em>On Restart</em> setting decides what happens if Rosco restarts while the script
+is running or waiting to start. With <em>abort</em> the run is forgotten. With <em>resume</em>
+it carries on as if Rosco hadn't stopped: steps that came due in the meantime run at once and
+fades pick up where they should be.
 </p>
 
 <p>
@@ -116,7 +133,11 @@ class Scripts extends UpdatingControlPanel<roscopb.Config> {
         nameDiv.innerText = script.name;
         this._table.appendChild(nameDiv);
         let actionsDiv = document.createElement('div');
-        actionsDiv.innerText = script.actions.length.toString();
+        if (script.tracks.length) {
+            actionsDiv.innerText = `${script.tracks.length} tracks`;
+        } else {
+            actionsDiv.innerText = script.actions.length.toString();
+        }
         this._table.appendChild(actionsDiv);
 
         let buttonsDiv = document.createElement('div');
@@ -128,9 +149,8 @@ class Scripts extends UpdatingControlPanel<roscopb.Config> {
         addAButton('Delete', 'Delete this script', buttonsDiv)
             .addEventListener('click', () => {
                 if (confirm(`Really delete ${script.name}?`)) {
-                    let scripts = this._ctrl.scripts.last;
-                    delete (scripts[id]);
-                    this._ctrl.scripts.save(scripts);
+                    this._ctrl.deleteScript(id)
+                        .catch((e) => alert(`Error deleting script: ${e.detail}`));
                 }
             });
 
@@ -169,10 +189,8 @@ class Scripts extends UpdatingControlPanel<roscopb.Config> {
     }
 
     private _saveScript(id: number, script: roscopb.Script) {
-        id = id ? id : Math.floor(Math.random() * 0x7fffffff);
-        let cfg = this._ctrl.cfg.last.clone();
-        cfg.scripts[id] = script;
-        this.save(cfg);
+        let saved = id ? this._ctrl.updateScript(id, script) : this._ctrl.createScript(script);
+        saved.catch((e) => alert(`Error saving script: ${e.detail}`));
     }
 
     private _runScript(script: roscopb.Script) {
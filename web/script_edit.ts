@@ -12,32 +12,94 @@ interface scriptActionEdit {
     valid(): boolean;
 }
 
+// ActionBeatsFields edit the beats or bars a sleep or fade lasts, which are
+// used instead of its duration
+class ActionBeatsFields {
+    private _beats: input.NumberField;
+    private _bars: input.NumberField;
+
+    constructor(action: roscopb.ScriptAction) {
+        this._beats = new input.NumberField('Beats');
+        this._beats.value = action.beats;
+        this._bars = new input.NumberField('Bars');
+        this._bars.value = action.bars;
+    }
+
+    elements(): HTMLElement[] {
+        return [...this._beats.elements(), ...this._bars.elements()];
+    }
+
+    apply(sa: roscopb.ScriptAction) {
+        sa.beats = this._beats.value;
+        sa.bars = this._bars.value;
+        if (sa.beats || sa.bars) {
+            sa.durationMs = 0;
+        }
+    }
+
+    valid(): boolean {
+        return this._beats.valid() && this._bars.valid();
+    }
+}
+
 class ScriptActionEditSleep {
     private _duration: input.Duration;
+    private _beats: ActionBeatsFields;
 
     constructor(action: roscopb.ScriptAction) {
         this._duration = new input.Duration();
         this._duration.value = action.durationMs;
+        this._beats = new ActionBeatsFields(action);
     }
 
     elements(): HTMLElement[] {
-        return this._duration.elements();
+        return [...this._duration.elements(), ...this._beats.elements()];
     }
 
     getAction(): roscopb.ScriptAction {
         let sa = new roscopb.ScriptAction();
         sa.type = roscopb.ScriptActionType.ActionTypeSleep;
         sa.durationMs = this._duration.value;
+        this._beats.apply(sa);
         return sa;
     }
 
     valid(): boolean {
-        return this._duration.valid();
+        return this._duration.valid()
+            && this._beats.valid();
+    }
+}
+
+// ActionTargetFields edit the optional target or role of a set or fade
+class ActionTargetFields {
+    private _target: input.TextField;
+    private _role: input.TextField;
+
+    constructor(action: roscopb.ScriptAction) {
+        this._target = new input.TextField('Target', false);
+        this._target.value = action.target;
+        this._role = new input.TextField('Role', false);
+        this._role.value = action.role;
+    }
+
+    elements(): HTMLElement[] {
+        return [...this._target.elements(), ...this._role.elements()];
+    }
+
+    apply(sa: roscopb.ScriptAction) {
+        sa.target = this._target.value;
+        sa.role = this._role.value;
+    }
+
+    valid(): boolean {
+        // an action may send to its own target or a role, not both
+        return !(this._target.value && this._role.value);
     }
 }
 
 class ScriptActionEditSet {
     private _address: input.OSCAddress;
+    private _targets: ActionTargetFields;
     private _value: input.OSCValue;
 
     constructor(action: roscopb.ScriptAction) {
@@ -46,33 +108,39 @@ class ScriptActionEditSet {
         }
         this._address = new input.OSCAddress();
         this._address.value = action.address;
+        this._targets = new ActionTargetFields(action);
         this._value = new input.OSCValue();
         this._value.value = action.values[0];
     }
 
     elements(): HTMLElement[] {
-        return [...this._address.elements(), ...this._value.elements()];
+        return [...this._address.elements(), ...this._targets.elements(), ...this._value.elements()];
     }
 
     getAction(): roscopb.ScriptAction {
         let sa = new roscopb.ScriptAction();
         sa.type = roscopb.ScriptActionType.ActionTypeSet;
         sa.address = this._address.value;
+        this._targets.apply(sa);
         sa.values.push(this._value.value);
         return sa;
     }
 
     valid(): boolean {
         return this._address.valid()
+            && this._targets.valid()
             && this._value.valid();
     }
 }
 
 class ScriptActionEditFade {
     private _address: input.OSCAddress;
+    private _targets: ActionTargetFields;
     private _from: input.FloatField;
     private _to: input.FloatField;
     private _duration: input.Duration;
+    private _beats: ActionBeatsFields;
+    private _fps: input.NumberField;
 
     constructor(action: roscopb.ScriptAction) {
         while (action.values.length < 2) {
@@ -81,20 +149,27 @@ class ScriptActionEditFade {
 
         this._address = new input.OSCAddress();
         this._address.value = action.address;
+        this._targets = new ActionTargetFields(action);
         this._from = new input.FloatField('From');
         this._from.value = action.values[0];
         this._to = new input.FloatField('To');
         this._to.value = action.values[1];
         this._duration = new input.Duration();
         this._duration.value = action.durationMs;
+        this._beats = new ActionBeatsFields(action);
+        this._fps = new input.NumberField('FPS');
+        this._fps.value = action.fps;
     }
 
     elements(): HTMLElement[] {
         return [
             ...this._address.elements(),
+            ...this._targets.elements(),
             ...this._from.elements(),
             ...this._to.elements(),
             ...this._duration.elements(),
+            ...this._beats.elements(),
+            ...this._fps.elements(),
         ];
     }
 
@@ -102,17 +177,55 @@ class ScriptActionEditFade {
         let sa = new roscopb.ScriptAction();
         sa.type = roscopb.ScriptActionType.ActionTypeFade;
         sa.address = this._address.value;
+        this._targets.apply(sa);
         sa.values.push(this._from.value)
         sa.values.push(this._to.value);
         sa.durationMs = this._duration.value;
+        this._beats.apply(sa);
+        sa.fps = this._fps.value;
         return sa;
     }
 
     valid(): boolean {
         return this._address.valid()
+            && this._targets.valid()
             && this._from.valid()
             && this._to.valid()
-            && this._duration.valid();
+            && this._duration.valid()
+            && this._beats.valid()
+            && this._fps.valid();
+    }
+}
+
+class ScriptActionEditMaster {
+    private _master: input.TextField;
+    private _level: input.FloatField;
+
+    constructor(action: roscopb.ScriptAction) {
+        if (action.values.length == 0) {
+            action.values.push(new roscopb.OSCValue());
+        }
+        this._master = new input.TextField('Master');
+        this._master.value = action.master;
+        this._level = new input.FloatField('Level');
+        this._level.value = action.values[0];
+    }
+
+    elements(): HTMLElement[] {
+        return [...this._master.elements(), ...this._level.elements()];
+    }
+
+    getAction(): roscopb.ScriptAction {
+        let sa = new roscopb.ScriptAction();
+        sa.type = roscopb.ScriptActionType.ActionTypeMaster;
+        sa.master = this._master.value;
+        sa.values.push(this._level.value);
+        return sa;
+    }
+
+    valid(): boolean {
+        return this._master.valid()
+            && this._level.valid();
     }
 }
 
@@ -141,6 +254,7 @@ class ScriptActionEdit extends GloballyStyledHTMLElement {
         <option value="0">Set</option>
         <option value="1">Fade</option>
         <option value="2">Sleep</option>
+        <option value="3">Master</option>
     </select>
 </div>
 
@@ -179,6 +293,9 @@ class ScriptActionEdit extends GloballyStyledHTMLElement {
             case '2':
                 this._scriptAction = new ScriptActionEditSleep(this._action);
                 break;
+            case '3':
+                this._scriptAction = new ScriptActionEditMaster(this._action);
+                break;
         }
         Array.from(this._div_fields.children)
             .filter((e) => !e.classList.contains('keep'))
@@ -224,8 +341,9 @@ table {
 <tr>
     <th>Type</th>
     <th>Address</th>
+    <th>Target</th>
     <th>Value</th>
-    <th>Duration (ms)</th>
+    <th>Duration</th>
     <th></th>
 </tr>
 `;
@@ -244,6 +362,14 @@ table {
         address.innerText = action.address ? action.address : '';
         tr.appendChild(address);
 
+        let target = document.createElement('td');
+        if (action.target) {
+            target.innerText = action.target;
+        } else if (action.role) {
+            target.innerText = `role: ${action.role}`;
+        }
+        tr.appendChild(target);
+
         let values = document.createElement('td');
         switch (action.type) {
             case roscopb.ScriptActionType.ActionTypeSet:
@@ -255,11 +381,20 @@ table {
             case roscopb.ScriptActionType.ActionTypeSleep:
                 values.innerText = '';
                 break;
+            case roscopb.ScriptActionType.ActionTypeMaster:
+                values.innerText = `${action.master}: ${action.values[0].value.value}`;
+                break;
         }
         tr.appendChild(values);
 
         let duration = document.createElement('td');
-        duration.innerText = action.durationMs ? action.durationMs.toString() : '';
+        if (action.beats) {
+            duration.innerText = `${action.beats} beats`;
+        } else if (action.bars) {
+            duration.innerText = `${action.bars} bars`;
+        } else if (action.durationMs) {
+            duration.innerText = `${action.durationMs}ms`;
+        }
         tr.appendChild(duration);
 
         let controls = document.createElement('td');
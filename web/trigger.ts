@@ -9,10 +9,27 @@ Triggers which provide a mechanism to manually run a script against a specific t
 trigger Rosco will provide a button you can click to activate it and a link you can use in tools 
 like Stream Deck to activate it with a custom button.
 </p>
+
+<p>
+Unchecking a trigger's checkbox disables it so its link does nothing until it's enabled again.
+A trigger can also belong to a named group. A grouped trigger only fires while its group is
+armed, so a whole set of triggers can be switched on or off at once.
+</p>
+
+<p>
+The Panic button stops every running script and sends each target its safe state. A panic
+trigger does the same from a link, whether or not its group is armed.
+</p>
+
+<p>
+Click the Tap button in time with the music to set the tempo that beat and bar timings follow.
+A tap tempo trigger does the same from a link.
+</p>
 `;
 
 class Triggers extends UpdatingControlPanel<roscopb.Config> {
     private _table: HTMLDivElement;
+    private _tapButton: HTMLButtonElement;
 
     private _ctrl: Controller;
 
@@ -22,6 +39,8 @@ class Triggers extends UpdatingControlPanel<roscopb.Config> {
         this._ctrl = ctrl;
 
         this.innerHTML = `
+<button id="panic" type="button" title="Stop all scripts and send safe states">Panic</button>
+<button id="tap" type="button" title="Tap in time to set the tempo">Tap</button>
 <div id="table" class="grid-4-col">
     <div class="column-header">Name</div>
     <div class="column-header">Target</div>
@@ -39,6 +58,9 @@ class Triggers extends UpdatingControlPanel<roscopb.Config> {
         this._table = this.querySelector('div#table');
 
         this.querySelector('button#new').addEventListener('click', () => newDialog.display(this.last.scripts));
+        this.querySelector('button#panic').addEventListener('click', () => this._panic());
+        this._tapButton = this.querySelector('button#tap');
+        this._tapButton.addEventListener('click', () => this._tap());
 
         this.update(ctrl.cfg.last);
     }
@@ -65,16 +87,35 @@ class Triggers extends UpdatingControlPanel<roscopb.Config> {
         this._addTableDiv(id);
         this._addTableDiv(trigger.target);
         let script = this.last.scripts[trigger.scriptId];
-        this._addTableDiv(script ? script.name : 'deleted!');
+        if (trigger.panic) {
+            this._addTableDiv('Panic');
+        } else if (trigger.tapTempo) {
+            this._addTableDiv('Tap Tempo');
+        } else {
+            this._addTableDiv(script ? script.name : 'deleted!');
+        }
 
         let buttonsDiv = this._addTableDiv('');
         let run = addAButton('Run', 'Activate this trigger', buttonsDiv);
-        run.disabled = !script;
-        run.addEventListener('click', () => this._ctrl.runScript(script, trigger.target));
+        if (trigger.panic) {
+            run.addEventListener('click', () => this._panic());
+        } else if (trigger.tapTempo) {
+            run.addEventListener('click', () => this._tap());
+        } else {
+            run.disabled = !script;
+            run.addEventListener('click', () => this._ctrl.runScript(script, trigger.target));
+        }
 
         addAButton('Delete', 'Delete this trigger', buttonsDiv)
             .addEventListener('click', () => this._delete(id));
 
+        let enabled = document.createElement('input');
+        enabled.type = 'checkbox';
+        enabled.title = 'Enable or disable this trigger';
+        enabled.checked = !trigger.disabled;
+        enabled.addEventListener('change', () => this._setEnabled(id, enabled.checked));
+        buttonsDiv.appendChild(enabled);
+
         let link = document.createElement('a');
         link.innerHTML = '&#x1F517;';
         link.href = `/m/26f36f67f6931ed9/_webhook?trigger=${id}`;
@@ -82,21 +123,40 @@ class Triggers extends UpdatingControlPanel<roscopb.Config> {
     }
 
     private _saveNew(id: string, target: string, scriptId: number) {
-        let cfg = this.last.clone();
-        cfg.triggers[id] = new roscopb.Trigger({
+        this._ctrl.createTrigger(id, new roscopb.Trigger({
             target,
             scriptId
-        });
-        this.save(cfg);
+        })).catch((e) => alert(`Error creating trigger: ${e.detail}`));
+    }
+
+    private _panic() {
+        this._ctrl.panic()
+            .catch((e) => alert(`Error panicking: ${e.detail}`));
+    }
+
+    private _tap() {
+        this._ctrl.tapTempo()
+            .then((resp) => {
+                if (resp.tempo?.bpm) {
+                    this._tapButton.innerText = `Tap (${Math.round(resp.tempo.bpm)} BPM)`;
+                }
+            })
+            .catch((e) => alert(`Error tapping tempo: ${e.detail}`));
+    }
+
+    private _setEnabled(id: string, enabled: boolean) {
+        let trigger = this.last.triggers[id].clone();
+        trigger.disabled = !enabled;
+        this._ctrl.updateTrigger(id, trigger)
+            .catch((e) => alert(`Error updating trigger: ${e.detail}`));
     }
 
     private _delete(id: string) {
         if (!confirm(`Really delete ${id}?`)) {
             return;
         }
-        let cfg = this.last.clone();
-        delete (cfg.triggers[id]);
-        this.save(cfg);
+        this._ctrl.deleteTrigger(id)
+            .catch((e) => alert(`Error deleting trigger: ${e.detail}`));
     }
 }
 customElements.define('rosco-triggers', Triggers, { extends: 'fieldset' });